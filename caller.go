@@ -0,0 +1,152 @@
+package slsh
+
+import (
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultCallerKey = "caller"
+
+	logrusPackage = "github.com/sirupsen/logrus"
+)
+
+// 调用位置文件路径格式
+type CallerPath int
+
+const (
+	CallerPathFull   CallerPath = iota // 完整路径, 与 runtime.Frame.File 一致
+	CallerPathModule                   // 相对于模块的路径, 例如: "internal/validator/validator.go"
+	CallerPathBase                     // 仅保留文件名, 例如: "validator.go"
+)
+
+// 调用位置字段配置, 需开启 logger.SetReportCaller(true)
+type CallerFields struct {
+	Key          string     // 合并字段 "<file>:<line>", 可选, 所有字段均为空时默认为 "caller"
+	FileKey      string     // 文件字段, 可选
+	LineKey      string     // 行号字段, 可选
+	FuncKey      string     // 函数字段, 可选
+	Path         CallerPath // 文件路径格式, 可选, 默认为完整路径
+	Module       string     // CallerPathModule 使用的模块路径, 可选, 默认读取 debug.ReadBuildInfo
+	SkipPackages []string   // 需要跳过的封装包 (或函数) 前缀, 可选
+}
+
+func (f CallerFields) Expand(contents map[string]string, entry *logrus.Entry) {
+	if !entry.HasCaller() {
+		return
+	}
+
+	frame := f.caller(entry)
+	file := f.file(frame)
+	line := strconv.Itoa(frame.Line)
+
+	f = f.withDefaults()
+	if f.Key != "" {
+		contents[f.Key] = file + ":" + line
+	}
+	if f.FileKey != "" {
+		contents[f.FileKey] = file
+	}
+	if f.LineKey != "" {
+		contents[f.LineKey] = line
+	}
+	if f.FuncKey != "" {
+		contents[f.FuncKey] = frame.Function
+	}
+}
+
+func (f CallerFields) withDefaults() CallerFields {
+	if f.Key == "" && f.FileKey == "" && f.LineKey == "" && f.FuncKey == "" {
+		f.Key = DefaultCallerKey
+	}
+	return f
+}
+
+func (f CallerFields) file(frame runtime.Frame) string {
+	switch f.Path {
+	case CallerPathBase:
+		return filepath.Base(frame.File)
+	case CallerPathModule:
+		pkg := packageName(frame.Function)
+		if pkg == "" {
+			return frame.File
+		}
+		file := path.Join(pkg, filepath.Base(frame.File))
+		if module := f.module(); module != "" {
+			file = strings.TrimPrefix(strings.TrimPrefix(file, module), "/")
+		}
+		return file
+	default:
+		return frame.File
+	}
+}
+
+func (f CallerFields) module() string {
+	if f.Module != "" {
+		return f.Module
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Path
+	}
+	return ""
+}
+
+// 默认使用 entry.Caller, 仅当其属于需要跳过的封装包, 或被部分 logrus 版本误报为
+// logrus 内部的帧时, 才从当前调用栈中重新查找
+func (f CallerFields) caller(entry *logrus.Entry) runtime.Frame {
+	frame := *entry.Caller
+	if packageName(frame.Function) == logrusPackage || f.skip(frame.Function) {
+		if caller, ok := f.lookup(frame); ok {
+			frame = caller
+		}
+	}
+	return frame
+}
+
+// 在当前调用栈中找到 origin, 并返回其后第一个不属于 logrus 与 SkipPackages 的帧,
+// 找不到 origin 时说明当前调用栈不属于该日志, 返回 false
+func (f CallerFields) lookup(origin runtime.Frame) (runtime.Frame, bool) {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	found := false
+	for {
+		frame, more := frames.Next()
+		if !found {
+			found = frame.Function == origin.Function && frame.File == origin.File && frame.Line == origin.Line
+		}
+		if found && packageName(frame.Function) != logrusPackage && !f.skip(frame.Function) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+func (f CallerFields) skip(function string) bool {
+	for _, prefix := range f.SkipPackages {
+		if !strings.HasPrefix(function, prefix) {
+			continue
+		}
+		if rest := function[len(prefix):]; rest == "" || rest[0] == '.' || rest[0] == '/' {
+			return true
+		}
+	}
+	return false
+}
+
+// 从函数全名中解析包路径, 例如: "github.com/sirupsen/logrus.(*Entry).Info" => "github.com/sirupsen/logrus"
+func packageName(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package slsh
+
+import (
+	"context"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const thisPackage = "github.com/GotaX/logrus-aliyun-log-hook"
+
+func logWrapper(logger *logrus.Logger, msg string) { logger.Info(msg) }
+func logA(logger *logrus.Logger)                   { logger.Error("a") }
+func logB(logger *logrus.Logger)                   { logger.Error("b") }
+
+func TestCallerFields(t *testing.T) {
+	capture := func(fields CallerFields, log func(logger *logrus.Logger)) (contents map[string]string) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.CallerFields = &fields
+
+		service := &MockService{
+			onPush: func(ctx context.Context, message Message) error {
+				contents = message.Contents
+				return nil
+			},
+			onStart: func() {},
+			onStop:  func(ctx context.Context) error { return nil },
+		}
+		logger := logrus.New()
+		logger.SetOutput(ioutil.Discard)
+		logger.SetReportCaller(true)
+		logger.AddHook(NewCustom(DefaultTimeout, DefaultVisibleLevels, c, nil, service))
+		log(logger)
+		return
+	}
+
+	t.Run("combined", func(t *testing.T) {
+		var line int
+		contents := capture(CallerFields{Path: CallerPathBase}, func(logger *logrus.Logger) {
+			_, _, line, _ = runtime.Caller(0)
+			logger.Info("Hi")
+		})
+		assert.Equal(t, "caller_test.go:"+strconv.Itoa(line+1), contents[DefaultCallerKey])
+	})
+
+	t.Run("split", func(t *testing.T) {
+		fields := CallerFields{FileKey: "file", LineKey: "line", FuncKey: "func", Path: CallerPathModule}
+		contents := capture(fields, func(logger *logrus.Logger) { logger.Info("Hi") })
+
+		assert.NotContains(t, contents, DefaultCallerKey)
+		assert.Equal(t, "caller_test.go", contents["file"])
+		assert.NotEmpty(t, contents["line"])
+		assert.Contains(t, contents["func"], thisPackage+".TestCallerFields")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		fields := CallerFields{FuncKey: "func"}
+		contents := capture(fields, func(logger *logrus.Logger) { logWrapper(logger, "Hi") })
+		assert.Equal(t, thisPackage+".logWrapper", contents["func"])
+
+		fields.SkipPackages = []string{thisPackage + ".logWrapper"}
+		contents = capture(fields, func(logger *logrus.Logger) { logWrapper(logger, "Hi") })
+		assert.Contains(t, contents["func"], thisPackage+".TestCallerFields")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.CallerFields = &CallerFields{}
+		msg := c.Message(&logrus.Entry{Logger: logrus.New(), Data: logrus.Fields{}})
+		assert.NotContains(t, msg.Contents, DefaultCallerKey)
+	})
+}
+
+func TestCallerDedup(t *testing.T) {
+	now := time.Now()
+	c := testConfig(nil)
+	c.CallerFields = &CallerFields{FuncKey: "func"}
+	c.Dedup = &Dedup{Window: time.Minute, MaxKeys: 1, now: func() time.Time { return now }}
+	if !assert.NoError(t, c.validate()) {
+		return
+	}
+
+	var pushed []Message
+	hook := NewCustom(DefaultTimeout, logrus.AllLevels, c.newConverter(), nil,
+		newMockService(func(ctx context.Context, message Message) error { pushed = append(pushed, message); return nil }))
+	hook.configure(c)
+
+	logger := newTestLogger(hook)
+	logger.SetReportCaller(true)
+	logA(logger)
+	logA(logger)
+	now = now.Add(time.Hour)
+	logB(logger)
+
+	// a 的折叠汇总在 logB 的调用栈中发送, 调用位置仍应为 logA
+	if assert.Len(t, pushed, 3) {
+		assert.Equal(t, thisPackage+".logA", pushed[0].Contents["func"])
+		assert.Equal(t, "1", pushed[1].Contents[RepeatCountKey])
+		assert.Equal(t, thisPackage+".logA", pushed[1].Contents["func"])
+		assert.Equal(t, thisPackage+".logB", pushed[2].Contents["func"])
+	}
+}
+
+func TestCallerPath(t *testing.T) {
+	frame := runtime.Frame{
+		File:     "/home/u/go/src/example.com/app/internal/db/conn.go",
+		Function: "example.com/app/internal/db.(*Conn).Open",
+	}
+	assert.Equal(t, frame.File, CallerFields{}.file(frame))
+	assert.Equal(t, "conn.go", CallerFields{Path: CallerPathBase}.file(frame))
+	assert.Equal(t, "internal/db/conn.go", CallerFields{Path: CallerPathModule, Module: "example.com/app"}.file(frame))
+	assert.Equal(t, "example.com/lib/db/conn.go", CallerFields{Path: CallerPathModule, Module: "example.com/app"}.file(runtime.Frame{
+		File:     frame.File,
+		Function: "example.com/lib/db.Open",
+	}))
+	assert.Equal(t, logrusPackage, packageName("github.com/sirupsen/logrus.(*Entry).Info"))
+}
@@ -0,0 +1,135 @@
+// slsh 命令行工具
+//
+// 解密导出日志中被 slsh.Encryptor 加密的字段:
+//
+//	slsh decrypt -key k1=<base64 key> [-key k2=<base64 key>] [-fields a,b] [-field a] < export.json
+//
+// 密文与字段名绑定, 输入为 JSON Lines 时按字段名解密, 否则按 -field 指定的字段名尝试解密整行.
+// 密钥也可以通过环境变量 SLSH_KEYS 传入, 格式: "k1=<base64 key>,k2=<base64 key>".
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	slsh "github.com/GotaX/logrus-aliyun-log-hook"
+)
+
+const usage = `Usage: slsh <command> [flags]
+
+Commands:
+  decrypt    decrypt encrypted fields from exported logs
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "decrypt":
+		return decrypt(args[1:], in, out)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+type keyFlag map[string][]byte
+
+func (k keyFlag) String() string { return "" }
+
+func (k keyFlag) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid key %q, expect <id>=<base64 key>", pair)
+		}
+		key, err := base64.StdEncoding.DecodeString(kv[1])
+		if err != nil {
+			return fmt.Errorf("invalid key %q: %v", kv[0], err)
+		}
+		k[kv[0]] = key
+	}
+	return nil
+}
+
+func decrypt(args []string, in io.Reader, out io.Writer) error {
+	keys := keyFlag{}
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	fs.Var(keys, "key", "decryption key `<id>=<base64 key>`, repeatable")
+	fields := fs.String("fields", "", "comma separated fields to decrypt, default all decryptable fields")
+	field := fs.String("field", "", "field name of non-JSON lines, which are kept as is when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if env := os.Getenv("SLSH_KEYS"); env != "" {
+		if err := keys.Set(env); err != nil {
+			return err
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("at least one -key is required")
+	}
+
+	provider := slsh.StaticKeyProvider{Keys: keys}
+	only := make(map[string]bool)
+	for _, field := range strings.Split(*fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			only[field] = true
+		}
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	w := bufio.NewWriter(out)
+	defer func() { _ = w.Flush() }()
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			if *field != "" {
+				if plaintext, err := slsh.Decrypt(provider, *field, strings.TrimSpace(line)); err == nil {
+					line = plaintext
+				}
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for k, v := range record {
+			s, ok := v.(string)
+			if !ok || len(only) > 0 && !only[k] {
+				continue
+			}
+			if plaintext, err := slsh.Decrypt(provider, k, s); err == nil {
+				record[k] = plaintext
+			}
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	slsh "github.com/GotaX/logrus-aliyun-log-hook"
+)
+
+func TestDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	provider := slsh.StaticKeyProvider{Current: "k1", Keys: map[string][]byte{"k1": key}}
+
+	customer, err := slsh.Encrypt(provider, "customer", "customer-1")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	in := strings.Join([]string{
+		`{"customer":"` + customer + `","message":"hi"}`,
+		customer,
+		"plain text",
+	}, "\n")
+
+	out := &bytes.Buffer{}
+	err = run([]string{"decrypt", "-key", "k1=" + base64.StdEncoding.EncodeToString(key), "-field", "customer"}, strings.NewReader(in), out)
+	if assert.NoError(t, err) {
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if assert.Len(t, lines, 3) {
+			assert.JSONEq(t, `{"customer":"customer-1","message":"hi"}`, lines[0])
+			assert.Equal(t, "customer-1", lines[1])
+			assert.Equal(t, "plain text", lines[2])
+		}
+	}
+
+	out.Reset()
+	err = run([]string{"decrypt", "-key", "k1=" + base64.StdEncoding.EncodeToString(key)},
+		strings.NewReader(`{"other":"`+customer+`"}`+"\n"+customer), out)
+	if assert.NoError(t, err) {
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.JSONEq(t, `{"other":"`+customer+`"}`, lines[0])
+			assert.Equal(t, customer, lines[1])
+		}
+	}
+
+	out.Reset()
+	err = run([]string{"decrypt", "-key", "k1=" + base64.StdEncoding.EncodeToString(key), "-fields", "other"},
+		strings.NewReader(`{"customer":"`+customer+`"}`), out)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"customer":"`+customer+`"}`, out.String())
+	}
+
+	assert.Error(t, run(nil, strings.NewReader(""), out))
+	assert.Error(t, run([]string{"unknown"}, strings.NewReader(""), out))
+	assert.Error(t, run([]string{"decrypt"}, strings.NewReader(""), out))
+	assert.Error(t, run([]string{"decrypt", "-key", "k1"}, strings.NewReader(""), out))
+}
@@ -0,0 +1,134 @@
+package slsh
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTraceIDKey = "trace_id"
+	DefaultSpanIDKey  = "span_id"
+)
+
+type (
+	traceparentKey struct{}
+	priorityKey    struct{}
+	storeKey       struct{}
+	mustDeliverKey struct{}
+)
+
+// 从 entry.Context 中提取字段
+type ContextExtractor interface {
+	Extract(ctx context.Context, contents map[string]string)
+}
+
+type ContextExtractorFunc func(context.Context, map[string]string)
+
+func (f ContextExtractorFunc) Extract(ctx context.Context, contents map[string]string) {
+	f(ctx, contents)
+}
+
+// 在 ctx 中保存 W3C traceparent, 格式: "00-<trace-id>-<parent-id>-<flags>"
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceparentKey{}, traceparent)
+}
+
+func TraceparentFromContext(ctx context.Context) (string, bool) {
+	traceparent, ok := ctx.Value(traceparentKey{}).(string)
+	return traceparent, ok
+}
+
+// 将 entry.Context 为 ctx 的日志标记为高优先级, 不受 Config.Priority.Level 限制
+func WithPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, priorityKey{}, true)
+}
+
+// 将 entry.Context 为 ctx 的日志写入 store, 而不是 Config.Store
+func WithStore(ctx context.Context, store string) context.Context {
+	return context.WithValue(ctx, storeKey{}, store)
+}
+
+// entry.Context 为 ctx 的日志跳过过滤, 采样, 折叠与限流, 写缓存时不受 Timeout 与 ctx 取消影响
+func WithMustDeliver(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mustDeliverKey{}, true)
+}
+
+func priorityFromContext(ctx context.Context) bool {
+	return ctx != nil && ctx.Value(priorityKey{}) == true
+}
+
+func storeFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	store, _ := ctx.Value(storeKey{}).(string)
+	return store
+}
+
+func mustDeliverFromContext(ctx context.Context) bool {
+	return ctx != nil && ctx.Value(mustDeliverKey{}) == true
+}
+
+// 从 ContextWithTraceparent 保存的 traceparent 中提取 trace id 与 span id
+func TraceparentExtractor(traceKey, spanKey string) ContextExtractor {
+	return ContextExtractorFunc(func(ctx context.Context, contents map[string]string) {
+		traceparent, ok := TraceparentFromContext(ctx)
+		if !ok {
+			return
+		}
+		if traceID, spanID, ok := parseTraceparent(traceparent); ok {
+			contents[traceKey] = traceID
+			contents[spanKey] = spanID
+		}
+	})
+}
+
+// 从 ctx 中取出 trace id 与 span id, ok 为 false 时表示不存在有效的 span
+type SpanIDsFunc func(ctx context.Context) (traceID, spanID string, ok bool)
+
+// 通过 from 提取 trace id 与 span id, 例如对接 OpenTelemetry:
+//
+//	SpanContextExtractor("trace_id", "span_id", func(ctx context.Context) (string, string, bool) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		return sc.TraceID().String(), sc.SpanID().String(), sc.IsValid()
+//	})
+func SpanContextExtractor(traceKey, spanKey string, from SpanIDsFunc) ContextExtractor {
+	return ContextExtractorFunc(func(ctx context.Context, contents map[string]string) {
+		traceID, spanID, ok := from(ctx)
+		if !ok || traceID == "" || spanID == "" {
+			return
+		}
+		contents[traceKey] = traceID
+		contents[spanKey] = spanID
+	})
+}
+
+// 保留 ctx 中的值, 但不继承其取消与超时, 用于延迟发送的汇总日志
+type detachedContext struct{ context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func parseTraceparent(traceparent string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		!isHex(parts[1], 32) || !isHex(parts[2], 16) || !isHex(parts[3], 2) ||
+		strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func isHex(s string, size int) bool {
+	if len(s) != size {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package slsh
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID      = "00f067aa0ba902b7"
+	testTraceparent = "00-" + testTraceID + "-" + testSpanID + "-01"
+)
+
+type otelTraceID [16]byte
+
+func (t otelTraceID) String() string { return hex.EncodeToString(t[:]) }
+
+type otelSpanID [8]byte
+
+func (s otelSpanID) String() string { return hex.EncodeToString(s[:]) }
+
+type otelSpanContext struct {
+	traceID otelTraceID
+	spanID  otelSpanID
+}
+
+func (s otelSpanContext) TraceID() otelTraceID { return s.traceID }
+func (s otelSpanContext) SpanID() otelSpanID   { return s.spanID }
+func (s otelSpanContext) IsValid() bool {
+	return s.traceID != otelTraceID{} && s.spanID != otelSpanID{}
+}
+
+type otelKey struct{}
+
+func TestContextExtractor(t *testing.T) {
+	t.Run("traceparent", func(t *testing.T) {
+		extractor := TraceparentExtractor("t", "s")
+
+		contents := make(map[string]string)
+		extractor.Extract(ContextWithTraceparent(context.TODO(), testTraceparent), contents)
+		assert.Equal(t, map[string]string{"t": testTraceID, "s": testSpanID}, contents)
+
+		for _, invalid := range []string{
+			"",
+			"00-" + testTraceID + "-" + testSpanID,
+			"ff-" + testTraceID + "-" + testSpanID + "-01",
+			"00-00000000000000000000000000000000-" + testSpanID + "-01",
+			"00-" + testTraceID + "-0000000000000000-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + testSpanID + "-01",
+		} {
+			contents := make(map[string]string)
+			extractor.Extract(ContextWithTraceparent(context.TODO(), invalid), contents)
+			assert.Empty(t, contents, invalid)
+		}
+
+		contents = make(map[string]string)
+		extractor.Extract(context.TODO(), contents)
+		assert.Empty(t, contents)
+	})
+
+	t.Run("span context", func(t *testing.T) {
+		var sc otelSpanContext
+		_, _ = hex.Decode(sc.traceID[:], []byte(testTraceID))
+		_, _ = hex.Decode(sc.spanID[:], []byte(testSpanID))
+
+		extractor := SpanContextExtractor(DefaultTraceIDKey, DefaultSpanIDKey, func(ctx context.Context) (string, string, bool) {
+			sc, _ := ctx.Value(otelKey{}).(otelSpanContext)
+			return sc.TraceID().String(), sc.SpanID().String(), sc.IsValid()
+		})
+
+		contents := make(map[string]string)
+		extractor.Extract(context.WithValue(context.TODO(), otelKey{}, sc), contents)
+		assert.Equal(t, testTraceID, contents[DefaultTraceIDKey])
+		assert.Equal(t, testSpanID, contents[DefaultSpanIDKey])
+
+		contents = make(map[string]string)
+		extractor.Extract(context.TODO(), contents)
+		assert.Empty(t, contents)
+	})
+
+	t.Run("converter", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.Extractors = []ContextExtractor{TraceparentExtractor(DefaultTraceIDKey, DefaultSpanIDKey)}
+
+		entry := logrus.NewEntry(logrus.New()).
+			WithContext(ContextWithTraceparent(context.TODO(), testTraceparent)).
+			WithTime(time.Now())
+
+		msg := c.Message(entry)
+		assert.Equal(t, testTraceID, msg.Contents[DefaultTraceIDKey])
+		assert.Equal(t, testSpanID, msg.Contents[DefaultSpanIDKey])
+
+		msg = c.Message(entry.WithField(DefaultTraceIDKey, "explicit"))
+		assert.Equal(t, "explicit", msg.Contents[DefaultTraceIDKey])
+	})
+}
+
+func TestContextOverrides(t *testing.T) {
+	var pushed, priority []Message
+	var pushErr error
+	service := newMockPriorityService(func(ctx context.Context, message Message) error {
+		pushErr = ctx.Err()
+		pushed = append(pushed, message)
+		return nil
+	}, func(ctx context.Context, message Message) error {
+		priority = append(priority, message)
+		return nil
+	})
+	hook := newTestHook(t, service, func(c *Config) {
+		c.Filter = func(entry *logrus.Entry) bool { return false }
+	})
+	logger := newTestLogger(hook)
+
+	logger.Info("filtered")
+	assert.Empty(t, pushed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	logger.WithContext(WithStore(WithMustDeliver(ctx), "audit")).Info("audit")
+	if assert.Len(t, pushed, 1) {
+		assert.Equal(t, "audit", pushed[0].Store)
+		assert.NoError(t, pushErr)
+	}
+
+	logger.WithContext(WithPriority(WithMustDeliver(context.Background()))).Info("priority")
+	if assert.Len(t, priority, 1) {
+		assert.Equal(t, "priority", priority[0].Contents["message"])
+	}
+
+	t.Run("entry context", func(t *testing.T) {
+		logger := newTestLogger(newTestHook(t, service, nil))
+		logger.WithContext(ctx).Info("cancelled")
+		assert.NoError(t, pushErr)
+
+		logger = newTestLogger(newTestHook(t, service, func(c *Config) { c.UseEntryContext = true }))
+		logger.WithContext(ctx).Info("cancelled")
+		assert.Equal(t, context.Canceled, pushErr)
+
+		logger.Info("no context")
+		assert.NoError(t, pushErr)
+	})
+
+	t.Run("entry context with service", func(t *testing.T) {
+		var flushed int
+		service := NewService(1000, time.Hour, func(messages ...Message) error {
+			flushed += len(messages)
+			return nil
+		})
+		hook := newTestHook(t, service, func(c *Config) { c.UseEntryContext = true })
+		logger := newTestLogger(hook)
+
+		for i := 0; i < 1000; i++ {
+			logger.WithContext(ctx).Error("client canceled")
+		}
+		assert.NoError(t, hook.Close())
+		assert.Equal(t, 1000, flushed)
+	})
+}
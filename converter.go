@@ -2,7 +2,9 @@ package slsh
 
 import (
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,11 +18,31 @@ type ContentModifierFunc func(map[string]string)
 func (f ContentModifierFunc) Modify(contents map[string]string) { f(contents) }
 
 type converter struct {
-	MessageKey   string
-	LevelKey     string
-	LevelMapping LevelMapping
-	Extra        map[string]string
-	Modifier     ContentModifier
+	MessageKey     string
+	LevelKey       string
+	LevelMapping   LevelMapping
+	LevelRenderer  LevelRenderer
+	Extra          map[string]string
+	ExtraPrefix    string
+	FieldPrefix    string
+	Source         string
+	SourceKey      string
+	TimeKey        string
+	TimeFormat     TimeFormat
+	FieldMap       map[string]string
+	Formatter      logrus.Formatter
+	FormatterOnly  bool
+	Enrichers      []Enricher
+	EnrichPolicy   EnrichPolicy
+	Modifier       ContentModifier
+	ErrorFields    *ErrorFields
+	CallerFields   *CallerFields
+	Extractors     []ContextExtractor
+	Redactor       *Redactor
+	Encryptor      *Encryptor
+	KeySanitizer   *KeySanitizer
+	ValueSanitizer *ValueSanitizer
+	metrics        *metrics
 }
 
 func NewConverter(messageKey, levelKey string,
@@ -34,17 +56,55 @@ func NewConverter(messageKey, levelKey string,
 		LevelMapping: levelMapping,
 		Extra:        extra,
 		Modifier:     modifier,
+		metrics:      &metrics{},
 	}
 }
 
 func (c converter) Message(entry *logrus.Entry) Message {
 	contents := make(map[string]string)
 	for k, v := range c.Extra {
-		contents[k] = v
+		contents[c.ExtraPrefix+k] = v
 	}
-	contents[c.MessageKey] = entry.Message
-	contents[c.LevelKey] = strconv.Itoa(c.LevelMapping(entry.Level))
-	for k, v := range entry.Data {
+	if c.SourceKey != "" {
+		contents[c.SourceKey] = c.Source
+	}
+	if c.TimeKey != "" {
+		format := c.TimeFormat
+		if format == nil {
+			format = RFC3339NanoTimeFormat
+		}
+		contents[c.TimeKey] = format(entry.Time)
+	}
+	contents[c.MessageKey] = c.message(entry)
+	if c.LevelRenderer != nil {
+		c.LevelRenderer.Render(contents, c.LevelKey, entry.Level)
+	} else {
+		contents[c.LevelKey] = strconv.Itoa(c.LevelMapping(entry.Level))
+	}
+	if c.CallerFields != nil {
+		c.CallerFields.Expand(contents, entry)
+	}
+	if entry.Context != nil {
+		for _, extractor := range c.Extractors {
+			extractor.Extract(entry.Context, contents)
+		}
+	}
+	var builtin map[string]bool
+	if c.KeySanitizer != nil || len(c.Enrichers) > 0 {
+		builtin = c.builtinKeys()
+	}
+	if c.EnrichPolicy == EnrichKeepEntry {
+		c.enrich(contents, builtin)
+	}
+	fields := entry.Data
+	if c.Formatter != nil && c.FormatterOnly {
+		fields = nil
+	}
+	for k, v := range fields {
+		k = c.FieldPrefix + k
+		if c.KeySanitizer != nil {
+			k = c.KeySanitizer.field(k, builtin)
+		}
 		switch v := v.(type) {
 		case string:
 			contents[k] = v
@@ -55,17 +115,115 @@ func (c converter) Message(entry *logrus.Entry) Message {
 			contents[k] = fmt.Sprintf("%f", v)
 		case bool:
 			contents[k] = strconv.FormatBool(v)
+		case error:
+			if c.ErrorFields != nil {
+				c.ErrorFields.Expand(contents, k, v)
+			} else {
+				contents[k] = errorMessage(v)
+			}
 		default:
 			contents[k] = fmt.Sprintf("%v", v)
 		}
 	}
 
+	if c.EnrichPolicy == EnrichOverride {
+		c.enrich(contents, builtin)
+	}
+
+	if len(c.FieldMap) > 0 {
+		renamed := make(map[string]string, len(c.FieldMap))
+		for from, to := range c.FieldMap {
+			if v, ok := contents[from]; ok {
+				delete(contents, from)
+				renamed[to] = v
+			}
+		}
+		for k, v := range renamed {
+			contents[k] = v
+		}
+	}
+
 	if c.Modifier != nil {
 		c.Modifier.Modify(contents)
 	}
 
+	// 在加密之前修正字段名, 密文与最终的字段名绑定
+	if c.KeySanitizer != nil {
+		c.KeySanitizer.Modify(contents)
+	}
+
+	if c.Redactor != nil {
+		c.Redactor.modify(contents, c.Encryptor.encrypted)
+	}
+
+	// 在加密之前修正字段值, 避免截断或转义破坏密文
+	if c.ValueSanitizer != nil {
+		c.ValueSanitizer.modify(contents, c.metrics)
+	}
+
+	if c.Encryptor != nil {
+		c.Encryptor.Modify(contents)
+	}
+
 	return Message{
 		Time:     entry.Time,
 		Contents: contents,
 	}
 }
+
+// 写入 Enricher 字段, 跳过 builtin 中的字段
+func (c converter) enrich(contents map[string]string, builtin map[string]bool) {
+	for _, enricher := range c.Enrichers {
+		for k, v := range enricher.Enrich() {
+			if !builtin[k] {
+				contents[k] = v
+			}
+		}
+	}
+}
+
+// 使用 Formatter 渲染日志内容, 失败时使用 entry.Message
+func (c converter) message(entry *logrus.Entry) string {
+	if c.Formatter == nil {
+		return entry.Message
+	}
+	data, err := c.Formatter.Format(entry)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Fail to format entry: %v\n", err)
+		return entry.Message
+	}
+	return strings.TrimRight(string(data), "\n")
+}
+
+// 由 converter 生成, 不允许被 entry.Data 覆盖的字段
+func (c converter) builtinKeys() map[string]bool {
+	keys := map[string]bool{c.MessageKey: true, c.LevelKey: true}
+	if c.SourceKey != "" {
+		keys[c.SourceKey] = true
+	}
+	if c.TimeKey != "" {
+		keys[c.TimeKey] = true
+	}
+	if c.CallerFields != nil {
+		f := c.CallerFields.withDefaults()
+		for _, key := range []string{f.Key, f.FileKey, f.LineKey, f.FuncKey} {
+			if key != "" {
+				keys[key] = true
+			}
+		}
+	}
+	return keys
+}
+
+// 延迟转换的日志 (例如: 折叠汇总) 需要在原调用栈中提前确定调用位置
+type callerResolver interface {
+	resolveCaller(entry *logrus.Entry)
+}
+
+// 在 Fire 的调用栈中确定调用位置, 写入 entry.Caller
+func (c converter) resolveCaller(entry *logrus.Entry) {
+	if c.CallerFields != nil && entry.HasCaller() {
+		frame := c.CallerFields.caller(entry)
+		entry.Caller = &frame
+	}
+}
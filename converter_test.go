@@ -61,3 +61,30 @@ func TestConverter(t *testing.T) {
 		assert.Equal(t, "INFO", msg.Contents[levelKey])
 	})
 }
+
+func TestConverterFormatter(t *testing.T) {
+	entry := &logrus.Entry{
+		Data:    logrus.Fields{"f1": "v1"},
+		Time:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Level:   logrus.InfoLevel,
+		Message: "content",
+	}
+
+	c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+	c.Formatter = &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true}
+
+	msg := c.Message(entry)
+	assert.Equal(t, `level=info msg=content f1=v1`, msg.Contents["message"])
+	assert.Equal(t, "v1", msg.Contents["f1"])
+
+	c.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+	c.FormatterOnly = true
+
+	msg = c.Message(entry)
+	assert.JSONEq(t, `{"level":"info","msg":"content","f1":"v1"}`, msg.Contents["message"])
+	assert.NotContains(t, msg.Contents, "f1")
+
+	c.Formatter = &logrus.JSONFormatter{}
+	msg = c.Message(&logrus.Entry{Data: logrus.Fields{"ch": make(chan int)}, Message: "content"})
+	assert.Equal(t, "content", msg.Contents["message"])
+}
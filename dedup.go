@@ -0,0 +1,191 @@
+package slsh
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+const (
+	DefaultDedupWindow  = 10 * time.Second
+	MinDedupWindow      = 10 * time.Millisecond
+	DefaultDedupMaxKeys = 1000
+
+	RepeatCountKey = "repeat_count"
+	FirstSeenKey   = "first_seen"
+	LastSeenKey    = "last_seen"
+)
+
+// 重复日志折叠, 相同 level, message 以及 Fields 的日志在窗口内只发送首条,
+// 窗口结束时发送一条汇总日志, 包含 repeat_count (不含首条), first_seen 与 last_seen
+type Dedup struct {
+	Window  time.Duration // 折叠窗口, 可选, 默认为 10s, 不小于 10ms
+	Fields  []string      // 参与比较的 entry.Data 字段, 可选, 默认仅比较 level 与 message
+	MaxKeys int           // 同时跟踪的最大日志数, 超出时不再折叠新日志, 可选, 默认为 1000
+	now     func() time.Time
+}
+
+func (d *Dedup) validate() error {
+	if d.Window < 0 || d.Window > 0 && d.Window < MinDedupWindow {
+		return validator.IllegalArgument("Dedup", "window should not be less than "+MinDedupWindow.String())
+	}
+	d.Window = validator.CoalesceDur(d.Window, DefaultDedupWindow)
+	d.MaxKeys = validator.CoalesceInt(d.MaxKeys, DefaultDedupMaxKeys)
+	return nil
+}
+
+type dedupGroup struct {
+	entry *logrus.Entry
+	rate  float64
+	first time.Time
+	last  time.Time
+	count int64
+}
+
+type deduplicator struct {
+	Dedup
+	emit    func(entry *logrus.Entry, rate float64)
+	resolve func(entry *logrus.Entry)
+	metrics *metrics
+	mu      sync.Mutex
+	groups  map[string]*dedupGroup
+	chQuit  chan struct{}
+	onClose sync.Once
+	wg      sync.WaitGroup
+}
+
+func newDeduplicator(d Dedup, m *metrics, emit func(entry *logrus.Entry, rate float64)) *deduplicator {
+	if d.now == nil {
+		d.now = time.Now
+	}
+	return &deduplicator{
+		Dedup:   d,
+		emit:    emit,
+		metrics: m,
+		groups:  make(map[string]*dedupGroup),
+		chQuit:  make(chan struct{}),
+	}
+}
+
+// 返回 false 时日志已被折叠, 无需发送
+func (d *deduplicator) allow(entry *logrus.Entry, rate float64) bool {
+	key := d.key(entry)
+	now := d.now()
+
+	d.mu.Lock()
+	g, ok := d.groups[key]
+	if ok && now.Sub(g.first) < d.Window {
+		g.count++
+		g.last = now
+		d.mu.Unlock()
+		atomic.AddInt64(&d.metrics.Deduplicated, 1)
+		return false
+	}
+
+	var expired []*dedupGroup
+	if ok {
+		expired = append(expired, g)
+		delete(d.groups, key)
+	} else if len(d.groups) >= d.MaxKeys {
+		expired = d.expire(now, false)
+	}
+	if len(d.groups) < d.MaxKeys {
+		dup := copyEntry(entry)
+		if d.resolve != nil {
+			d.resolve(dup)
+		}
+		d.groups[key] = &dedupGroup{entry: dup, rate: rate, first: now, last: now}
+	}
+	d.mu.Unlock()
+
+	d.summarize(expired)
+	return true
+}
+
+func (d *deduplicator) key(entry *logrus.Entry) string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "%d\x00%s", entry.Level, entry.Message)
+	for _, field := range d.Fields {
+		_, _ = fmt.Fprintf(&sb, "\x00%v", entry.Data[field])
+	}
+	return sb.String()
+}
+
+// 移除窗口已结束的日志, 需持有锁
+func (d *deduplicator) expire(now time.Time, all bool) (expired []*dedupGroup) {
+	for key, g := range d.groups {
+		if all || now.Sub(g.first) >= d.Window {
+			expired = append(expired, g)
+			delete(d.groups, key)
+		}
+	}
+	return
+}
+
+func (d *deduplicator) summarize(groups []*dedupGroup) {
+	for _, g := range groups {
+		if g.count <= 0 {
+			continue
+		}
+		entry := copyEntry(g.entry)
+		entry.Time = g.last
+		entry.Data[RepeatCountKey] = g.count
+		entry.Data[FirstSeenKey] = g.first.Format(time.RFC3339Nano)
+		entry.Data[LastSeenKey] = g.last.Format(time.RFC3339Nano)
+		d.emit(entry, g.rate)
+	}
+}
+
+func (d *deduplicator) flush(all bool) {
+	d.mu.Lock()
+	expired := d.expire(d.now(), all)
+	d.mu.Unlock()
+	d.summarize(expired)
+}
+
+// 启动定时汇总
+func (d *deduplicator) Start() {
+	d.wg.Add(1)
+	go d.loop()
+}
+
+func (d *deduplicator) loop() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.Window / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.flush(false)
+		case <-d.chQuit:
+			return
+		}
+	}
+}
+
+// 停止定时汇总, 并立即发送所有未结束窗口的汇总日志
+func (d *deduplicator) Stop() {
+	d.onClose.Do(func() {
+		close(d.chQuit)
+		d.wg.Wait()
+		d.flush(true)
+	})
+}
+
+// logrus 会复用 Entry, 保存前需要复制
+func copyEntry(entry *logrus.Entry) *logrus.Entry {
+	dup := *entry
+	dup.Data = make(logrus.Fields, len(entry.Data)+3)
+	for k, v := range entry.Data {
+		dup.Data[k] = v
+	}
+	return &dup
+}
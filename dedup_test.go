@@ -0,0 +1,107 @@
+package slsh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedup(t *testing.T) {
+	now := time.Unix(1577836800, 0).UTC()
+	var emitted []*logrus.Entry
+	d := newDeduplicator(Dedup{Window: time.Second, Fields: []string{"dep"}, MaxKeys: 2, now: func() time.Time { return now }},
+		&metrics{}, func(entry *logrus.Entry, rate float64) { emitted = append(emitted, entry) })
+
+	entry := func(msg, dep string) *logrus.Entry {
+		return &logrus.Entry{Level: logrus.ErrorLevel, Message: msg, Data: logrus.Fields{"dep": dep}}
+	}
+
+	assert.True(t, d.allow(entry("timeout", "db"), 1))
+	assert.True(t, d.allow(entry("timeout", "cache"), 1))
+	for i := 0; i < 5; i++ {
+		now = now.Add(100 * time.Millisecond)
+		assert.False(t, d.allow(entry("timeout", "db"), 1))
+	}
+	assert.Equal(t, int64(5), d.metrics.Deduplicated)
+
+	t.Run("bounded", func(t *testing.T) {
+		assert.True(t, d.allow(entry("other", "db"), 1))
+		assert.True(t, d.allow(entry("other", "db"), 1))
+		assert.Len(t, d.groups, 2)
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		now = now.Add(time.Second)
+		assert.True(t, d.allow(entry("timeout", "db"), 1))
+		if assert.Len(t, emitted, 1) {
+			assert.Equal(t, "timeout", emitted[0].Message)
+			assert.Equal(t, "db", emitted[0].Data["dep"])
+			assert.Equal(t, int64(5), emitted[0].Data[RepeatCountKey])
+			assert.Equal(t, "2020-01-01T00:00:00Z", emitted[0].Data[FirstSeenKey])
+			assert.Equal(t, "2020-01-01T00:00:00.5Z", emitted[0].Data[LastSeenKey])
+		}
+		assert.Len(t, d.groups, 2)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		emitted = nil
+		assert.False(t, d.allow(entry("timeout", "db"), 1))
+		d.Start()
+		d.Stop()
+		if assert.Len(t, emitted, 1) {
+			assert.Equal(t, int64(1), emitted[0].Data[RepeatCountKey])
+		}
+		assert.Empty(t, d.groups)
+	})
+
+	assert.Error(t, (&Dedup{Window: -time.Second}).validate())
+	assert.Error(t, (&Dedup{Window: time.Nanosecond}).validate())
+	assert.NoError(t, (&Dedup{Window: MinDedupWindow}).validate())
+}
+
+func TestHookDedup(t *testing.T) {
+	var pushed []Message
+	hook := newTestHook(t, newMockService(func(ctx context.Context, message Message) error {
+		pushed = append(pushed, message)
+		return nil
+	}), func(c *Config) { c.Dedup = &Dedup{Window: time.Hour, MaxKeys: 10} })
+	logger := newTestLogger(hook)
+
+	for i := 0; i < 100; i++ {
+		logger.Error("connection refused")
+	}
+	assert.Len(t, pushed, 1)
+	assert.NoError(t, hook.Close())
+	assert.Len(t, pushed, 2)
+	assert.Equal(t, int64(99), hook.Metrics().Deduplicated)
+}
+
+func TestHookDedupContext(t *testing.T) {
+	var pushed []Message
+	hook := newTestHook(t, newMockService(func(ctx context.Context, message Message) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		pushed = append(pushed, message)
+		return nil
+	}), func(c *Config) {
+		c.UseEntryContext = true
+		c.Dedup = &Dedup{Window: time.Hour, MaxKeys: 10}
+	})
+	logger := newTestLogger(hook)
+
+	ctx, cancel := context.WithCancel(WithStore(context.Background(), "audit"))
+	for i := 0; i < 3; i++ {
+		logger.WithContext(ctx).Error("connection refused")
+	}
+	cancel()
+
+	assert.NoError(t, hook.Close())
+	if assert.Len(t, pushed, 2) {
+		assert.Equal(t, "connection refused", pushed[1].Contents["message"])
+		assert.Equal(t, "audit", pushed[1].Store)
+	}
+}
@@ -0,0 +1,142 @@
+package slsh
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+// 字段加密密钥来源
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error) // 加密使用的密钥
+	Key(id string) ([]byte, error)                  // 按 id 查找解密密钥
+}
+
+// 静态密钥, 适用于从配置或环境变量中读取的密钥
+type StaticKeyProvider struct {
+	Current string            // 加密使用的密钥 id
+	Keys    map[string][]byte // 密钥 id => AES 密钥 (16, 24 或 32 字节)
+}
+
+func (p StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.Current)
+	return p.Current, key, err
+}
+
+func (p StaticKeyProvider) Key(id string) ([]byte, error) {
+	if key, ok := p.Keys[id]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key %q not found", id)
+}
+
+// 使用 AES-GCM 加密指定字段, 密文格式: "<key id>:<base64(nonce + ciphertext)>"
+type Encryptor struct {
+	Keys     []string // 需要加密的字段名 glob, 不区分大小写
+	Provider KeyProvider
+}
+
+func (e *Encryptor) validate() error {
+	if e.Provider == nil {
+		return validator.IllegalArgument("Encryptor", "requires key provider")
+	}
+	id, key, err := e.Provider.CurrentKey()
+	if err != nil {
+		return validator.IllegalArgument("Encryptor", err.Error())
+	}
+	if strings.Contains(id, ":") {
+		return validator.IllegalArgument("Encryptor", "key id must not contain ':'")
+	}
+	if _, err = aes.NewCipher(key); err != nil {
+		return validator.IllegalArgument("Encryptor", err.Error())
+	}
+	return nil
+}
+
+// 加密失败的字段会被删除, 保证明文不会被发送
+func (e *Encryptor) Modify(contents map[string]string) {
+	for k, v := range contents {
+		if !e.encrypted(k) {
+			continue
+		}
+		if ciphertext, err := Encrypt(e.Provider, k, v); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Fail to encrypt field %q: %v\n", k, err)
+			delete(contents, k)
+		} else {
+			contents[k] = ciphertext
+		}
+	}
+}
+
+func (e *Encryptor) encrypted(key string) bool {
+	return e != nil && matchKey(e.Keys, key)
+}
+
+// 以 "<key id>:<field>" 作为附加数据加密, 密文只能在同名字段中解密, 不能被移动到其他字段
+func Encrypt(provider KeyProvider, field, plaintext string) (string, error) {
+	id, key, err := provider.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	data := aead.Seal(nonce, nonce, []byte(plaintext), additionalData(id, field))
+	return id + ":" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// 解密字段 field 的密文, field 须与加密时一致
+func Decrypt(provider KeyProvider, field, ciphertext string) (string, error) {
+	i := strings.IndexByte(ciphertext, ':')
+	if i < 0 {
+		return "", errors.New("missing key id")
+	}
+	key, err := provider.Key(ciphertext[:i])
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext[i+1:])
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData(ciphertext[:i], field))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// 密钥 id 不包含 ':', 因此 "<key id>:<field>" 没有歧义
+func additionalData(id, field string) []byte {
+	return []byte(id + ":" + field)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
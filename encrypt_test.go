@@ -0,0 +1,146 @@
+package slsh
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptor(t *testing.T) {
+	provider := StaticKeyProvider{
+		Current: "k2",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 16),
+			"k2": bytes.Repeat([]byte{2}, 32),
+		},
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		ciphertext, err := Encrypt(provider, "customer_id", "customer-1")
+		if assert.NoError(t, err) {
+			assert.True(t, strings.HasPrefix(ciphertext, "k2:"))
+			assert.NotContains(t, ciphertext, "customer-1")
+
+			plaintext, err := Decrypt(provider, "customer_id", ciphertext)
+			assert.NoError(t, err)
+			assert.Equal(t, "customer-1", plaintext)
+		}
+
+		old, err := Encrypt(StaticKeyProvider{Current: "k1", Keys: provider.Keys}, "customer_id", "customer-1")
+		if assert.NoError(t, err) {
+			plaintext, err := Decrypt(provider, "customer_id", old)
+			assert.NoError(t, err)
+			assert.Equal(t, "customer-1", plaintext)
+		}
+	})
+
+	t.Run("decrypt error", func(t *testing.T) {
+		ciphertext, _ := Encrypt(provider, "customer_id", "customer-1")
+
+		for _, invalid := range []string{
+			"plain",
+			"k3:" + ciphertext[3:],
+			"k2:!!!",
+			"k2:AAAA",
+			"k1:" + ciphertext[3:],
+		} {
+			_, err := Decrypt(provider, "customer_id", invalid)
+			assert.Error(t, err, invalid)
+		}
+	})
+
+	t.Run("bound to field", func(t *testing.T) {
+		e := &Encryptor{Keys: []string{"customer_*"}, Provider: provider}
+		contents := map[string]string{"customer_id": "customer-1", "customer_name": "alice"}
+		e.Modify(contents)
+
+		_, err := Decrypt(provider, "customer_name", contents["customer_id"])
+		assert.Error(t, err)
+		contents["customer_id"], contents["customer_name"] = contents["customer_name"], contents["customer_id"]
+		_, err = Decrypt(provider, "customer_id", contents["customer_id"])
+		assert.Error(t, err)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		assert.NoError(t, (&Encryptor{Provider: provider}).validate())
+		assert.Error(t, (&Encryptor{}).validate())
+		assert.Error(t, (&Encryptor{Provider: StaticKeyProvider{Current: "k3"}}).validate())
+		assert.Error(t, (&Encryptor{Provider: StaticKeyProvider{Current: "k:1", Keys: map[string][]byte{"k:1": provider.Keys["k1"]}}}).validate())
+		assert.Error(t, (&Encryptor{Provider: StaticKeyProvider{Current: "k1", Keys: map[string][]byte{"k1": {1}}}}).validate())
+	})
+
+	t.Run("converter", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.Encryptor = &Encryptor{Keys: []string{"customer_*"}, Provider: provider}
+		c.Redactor = &Redactor{Rules: DefaultRedactRules, Salt: []byte("salt")}
+
+		entry := &logrus.Entry{
+			Data:    logrus.Fields{"customer_phone": "13812345678", "customer_token": "t0k3n", "phone": "13812345678"},
+			Time:    time.Now(),
+			Level:   logrus.InfoLevel,
+			Message: "audit",
+		}
+		msg := c.Message(entry)
+		assert.Equal(t, "*******5678", msg.Contents["phone"])
+
+		for k, plain := range map[string]string{"customer_phone": "13812345678", "customer_token": "t0k3n"} {
+			plaintext, err := Decrypt(provider, k, msg.Contents[k])
+			assert.NoError(t, err)
+			assert.Equal(t, plain, plaintext)
+		}
+
+		w := NewWriter(&url.URL{}, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, nil)
+		raw, err := w.encode(msg)
+		if assert.NoError(t, err) {
+			assert.False(t, bytes.Contains(raw, []byte("t0k3n")))
+		}
+	})
+
+	t.Run("value sanitizer", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.Encryptor = &Encryptor{Keys: []string{"customer_*"}, Provider: provider}
+		c.ValueSanitizer = &ValueSanitizer{MaxSize: 8, TruncateSuffix: "..."}
+
+		entry := &logrus.Entry{
+			Data:    logrus.Fields{"customer_address": "No. 1 Long Street\xff"},
+			Time:    time.Now(),
+			Level:   logrus.InfoLevel,
+			Message: "audit",
+		}
+		msg := c.Message(entry)
+		assert.Equal(t, "audit", msg.Contents["message"])
+
+		plaintext, err := Decrypt(provider, "customer_address", msg.Contents["customer_address"])
+		assert.NoError(t, err)
+		assert.Equal(t, "No. 1 Lo...", plaintext)
+	})
+
+	t.Run("key sanitizer", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.Encryptor = &Encryptor{Keys: []string{"customer_*"}, Provider: provider}
+		c.KeySanitizer = &KeySanitizer{}
+
+		entry := &logrus.Entry{
+			Data:    logrus.Fields{"customer id": "customer-1"},
+			Time:    time.Now(),
+			Level:   logrus.InfoLevel,
+			Message: "audit",
+		}
+		msg := c.Message(entry)
+		plaintext, err := Decrypt(provider, "customer_id", msg.Contents["customer_id"])
+		assert.NoError(t, err)
+		assert.Equal(t, "customer-1", plaintext)
+	})
+
+	t.Run("encrypt error", func(t *testing.T) {
+		e := &Encryptor{Keys: []string{"customer"}, Provider: StaticKeyProvider{Current: "k3"}}
+		contents := map[string]string{"customer": "customer-1", "other": "v"}
+		e.Modify(contents)
+		assert.Equal(t, map[string]string{"other": "v"}, contents)
+	})
+}
@@ -0,0 +1,70 @@
+package slsh
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 字段冲突时的取值规则
+type EnrichPolicy int
+
+const (
+	EnrichKeepEntry EnrichPolicy = iota // entry.Data 字段优先
+	EnrichOverride                      // Enricher 字段优先, 但不会覆盖 MessageKey, LevelKey 等内置字段
+)
+
+// 在每条日志转换时计算附加字段
+type Enricher interface {
+	Enrich() map[string]string
+}
+
+type EnricherFunc func() map[string]string
+
+func (f EnricherFunc) Enrich() map[string]string { return f() }
+
+type cachedEnricher struct {
+	enricher Enricher
+	ttl      time.Duration
+	mu       sync.Mutex
+	expires  time.Time
+	fields   map[string]string
+}
+
+// 缓存 e 的结果 ttl 时间, ttl <= 0 时仅计算一次
+func CachedEnricher(e Enricher, ttl time.Duration) Enricher {
+	return &cachedEnricher{enricher: e, ttl: ttl}
+}
+
+func (c *cachedEnricher) Enrich() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fields == nil || c.ttl > 0 && time.Now().After(c.expires) {
+		c.fields = c.enricher.Enrich()
+		if c.fields == nil {
+			c.fields = map[string]string{}
+		}
+		c.expires = time.Now().Add(c.ttl)
+	}
+	return c.fields
+}
+
+// 当前 goroutine 数量
+func GoroutineEnricher(key string) Enricher {
+	return EnricherFunc(func() map[string]string {
+		return map[string]string{key: strconv.Itoa(runtime.NumGoroutine())}
+	})
+}
+
+// 主模块版本, 来自 debug.ReadBuildInfo
+func BuildInfoEnricher(key string) Enricher {
+	return CachedEnricher(EnricherFunc(func() map[string]string {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			return map[string]string{key: info.Main.Version}
+		}
+		return nil
+	}), 0)
+}
@@ -0,0 +1,62 @@
+package slsh
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnricher(t *testing.T) {
+	t.Run("cached", func(t *testing.T) {
+		var calls int64
+		e := CachedEnricher(EnricherFunc(func() map[string]string {
+			return map[string]string{"n": strconv.FormatInt(atomic.AddInt64(&calls, 1), 10)}
+		}), 20*time.Millisecond)
+
+		assert.Equal(t, "1", e.Enrich()["n"])
+		assert.Equal(t, "1", e.Enrich()["n"])
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, "2", e.Enrich()["n"])
+
+		once := CachedEnricher(EnricherFunc(func() map[string]string { atomic.AddInt64(&calls, 1); return nil }), 0)
+		assert.Empty(t, once.Enrich())
+		assert.Empty(t, once.Enrich())
+		assert.Equal(t, int64(3), atomic.LoadInt64(&calls))
+	})
+
+	t.Run("builtin", func(t *testing.T) {
+		n, err := strconv.Atoi(GoroutineEnricher("goroutines").Enrich()["goroutines"])
+		assert.NoError(t, err)
+		assert.True(t, n > 0)
+
+		assert.Contains(t, BuildInfoEnricher("version").Enrich(), "version")
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, map[string]string{"role": "static"}, nil)
+		c.Enrichers = []Enricher{EnricherFunc(func() map[string]string {
+			return map[string]string{"role": "leader", "flag": "on", "message": "enriched"}
+		})}
+		entry := &logrus.Entry{
+			Data:    logrus.Fields{"flag": "off"},
+			Time:    time.Now(),
+			Level:   logrus.InfoLevel,
+			Message: "content",
+		}
+
+		msg := c.Message(entry)
+		assert.Equal(t, "leader", msg.Contents["role"])
+		assert.Equal(t, "off", msg.Contents["flag"])
+		assert.Equal(t, "content", msg.Contents["message"])
+
+		c.EnrichPolicy = EnrichOverride
+		msg = c.Message(entry)
+		assert.Equal(t, "leader", msg.Contents["role"])
+		assert.Equal(t, "on", msg.Contents["flag"])
+		assert.Equal(t, "content", msg.Contents["message"])
+	})
+}
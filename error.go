@@ -0,0 +1,111 @@
+package slsh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+const (
+	DefaultMaxStackSize = 4096
+	DefaultMaxCauses    = 8
+
+	truncatedSuffix = "...(truncated)"
+)
+
+// error 字段展开配置
+type ErrorFields struct {
+	MaxStackSize int // <key>.stack 最大字节数, 可选, 默认为 4096
+	MaxCauses    int // <key>.causes 最大层数, 可选, 默认为 8
+}
+
+// 将 err 展开为 <key>.message, <key>.type, <key>.stack, <key>.causes 字段
+func (f ErrorFields) Expand(contents map[string]string, key string, err error) {
+	contents[key+".message"] = errorMessage(err)
+	contents[key+".type"] = fmt.Sprintf("%T", err)
+	if isNilError(err) {
+		return
+	}
+
+	chain := errorChain(err)
+	if stack := errorStack(chain); stack != "" {
+		contents[key+".stack"] = truncate(stack, validator.CoalesceInt(f.MaxStackSize, DefaultMaxStackSize), truncatedSuffix)
+	}
+
+	if len(chain) > 1 {
+		causes := make([]string, 0, len(chain)-1)
+		for _, cause := range chain[1:] {
+			if len(causes) == validator.CoalesceInt(f.MaxCauses, DefaultMaxCauses) {
+				break
+			}
+			causes = append(causes, cause.Error())
+		}
+		data, _ := json.Marshal(causes)
+		contents[key+".causes"] = string(data)
+	}
+}
+
+// 按 errors.Unwrap 或 pkg/errors 的 Cause() 展开错误链, 第一个元素为 err 本身
+func errorChain(err error) []error {
+	chain := make([]error, 0, 4)
+	for !isNilError(err) && len(chain) < 64 {
+		chain = append(chain, err)
+		if next := errors.Unwrap(err); next != nil {
+			err = next
+		} else if c, ok := err.(interface{ Cause() error }); ok {
+			err = c.Cause()
+		} else {
+			err = nil
+		}
+	}
+	return chain
+}
+
+// 取错误链中最深处的调用栈, 支持 pkg/errors 的 StackTrace() 方法
+func errorStack(chain []error) (stack string) {
+	for _, err := range chain {
+		m := reflect.ValueOf(err).MethodByName("StackTrace")
+		if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+			continue
+		}
+		if s := strings.TrimLeft(fmt.Sprintf("%+v", m.Call(nil)[0].Interface()), "\n"); s != "" {
+			stack = s
+		}
+	}
+	return
+}
+
+// err 为 nil 或值为 nil 的指针等, 例如: var e *MyErr; logger.WithError(e)
+func isNilError(err error) bool {
+	if err == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(err); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// nil 错误按 fmt.Sprint 格式化 (通常为 "<nil>"), 避免调用 Error() 时 panic
+func errorMessage(err error) string {
+	if isNilError(err) {
+		return fmt.Sprint(err)
+	}
+	return err.Error()
+}
+
+func truncate(s string, size int, suffix string) string {
+	if size <= 0 || len(s) <= size {
+		return s
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size] + suffix
+}
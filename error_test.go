@@ -0,0 +1,125 @@
+package slsh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStack []string
+
+func (s fakeStack) Format(st fmt.State, verb rune) {
+	for _, frame := range s {
+		_, _ = fmt.Fprintf(st, "\n%s", frame)
+	}
+}
+
+type stackError struct {
+	msg   string
+	stack fakeStack
+}
+
+func (e stackError) Error() string         { return e.msg }
+func (e stackError) StackTrace() fakeStack { return e.stack }
+
+type causeError struct {
+	msg   string
+	cause error
+}
+
+func (e causeError) Error() string { return e.msg + ": " + e.cause.Error() }
+func (e causeError) Cause() error  { return e.cause }
+
+type pointerError struct{ msg string }
+
+func (e *pointerError) Error() string { return e.msg }
+
+func TestErrorFields(t *testing.T) {
+	root := stackError{msg: "root", stack: fakeStack{"main.a\n\ta.go:1", "main.b\n\tb.go:2"}}
+	wrapped := fmt.Errorf("outer: %w", causeError{msg: "middle", cause: root})
+
+	t.Run("expand", func(t *testing.T) {
+		contents := make(map[string]string)
+		ErrorFields{}.Expand(contents, "error", wrapped)
+
+		assert.Equal(t, wrapped.Error(), contents["error.message"])
+		assert.Equal(t, fmt.Sprintf("%T", wrapped), contents["error.type"])
+		assert.Equal(t, "main.a\n\ta.go:1\nmain.b\n\tb.go:2", contents["error.stack"])
+
+		var causes []string
+		if assert.NoError(t, json.Unmarshal([]byte(contents["error.causes"]), &causes)) {
+			assert.Equal(t, []string{"middle: root", "root"}, causes)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		contents := make(map[string]string)
+		ErrorFields{MaxStackSize: 6, MaxCauses: 1}.Expand(contents, "err", wrapped)
+
+		assert.Equal(t, "main.a"+truncatedSuffix, contents["err.stack"])
+		assert.JSONEq(t, `["middle: root"]`, contents["err.causes"])
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		contents := make(map[string]string)
+		ErrorFields{}.Expand(contents, "error", errors.New("e"))
+
+		assert.Equal(t, "e", contents["error.message"])
+		assert.NotContains(t, contents, "error.stack")
+		assert.NotContains(t, contents, "error.causes")
+	})
+
+	t.Run("converter", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		entry := &logrus.Entry{
+			Data:    logrus.Fields{logrus.ErrorKey: wrapped},
+			Time:    time.Now(),
+			Level:   logrus.ErrorLevel,
+			Message: "content",
+		}
+
+		msg := c.Message(entry)
+		assert.Equal(t, wrapped.Error(), msg.Contents[logrus.ErrorKey])
+
+		c.ErrorFields = &ErrorFields{}
+		msg = c.Message(entry)
+		assert.NotContains(t, msg.Contents, logrus.ErrorKey)
+		assert.Equal(t, wrapped.Error(), msg.Contents["error.message"])
+		assert.True(t, strings.HasPrefix(msg.Contents["error.stack"], "main.a"))
+	})
+}
+
+func TestNilError(t *testing.T) {
+	var typed *pointerError
+	c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+	entry := logrus.NewEntry(logrus.New()).WithError(typed)
+	entry.Message = "content"
+
+	msg := c.Message(entry)
+	assert.Equal(t, "<nil>", msg.Contents[logrus.ErrorKey])
+	assert.Equal(t, "content", msg.Contents["message"])
+
+	c.ErrorFields = &ErrorFields{}
+	msg = c.Message(entry)
+	assert.Equal(t, "<nil>", msg.Contents["error.message"])
+	assert.Equal(t, "*slsh.pointerError", msg.Contents["error.type"])
+	assert.NotContains(t, msg.Contents, "error.causes")
+
+	contents := make(map[string]string)
+	ErrorFields{}.Expand(contents, "error", fmt.Errorf("outer: %w", typed))
+	assert.Equal(t, "outer: <nil>", contents["error.message"])
+	assert.NotContains(t, contents, "error.causes")
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "abc", truncate("abc", 3, truncatedSuffix))
+	assert.Equal(t, "abc", truncate("abc", 0, truncatedSuffix))
+	assert.Equal(t, "ab"+truncatedSuffix, truncate("abc", 2, truncatedSuffix))
+	assert.Equal(t, "a"+truncatedSuffix, truncate("a中", 2, truncatedSuffix))
+}
@@ -0,0 +1,109 @@
+package slsh
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/expr"
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+const funcFilterName = "Filter"
+
+// 日志过滤, 返回 false 时丢弃
+type EntryFilter func(entry *logrus.Entry) bool
+
+// 过滤规则, keep 返回 false 时丢弃日志
+type filterRule struct {
+	dropped int64
+	name    string
+	keep    EntryFilter
+}
+
+// 编译 Config.Filter 与 Config.FilterRules
+func newFilterRules(filter EntryFilter, rules []string) ([]*filterRule, error) {
+	filters := make([]*filterRule, 0, len(rules)+1)
+	if filter != nil {
+		filters = append(filters, &filterRule{name: funcFilterName, keep: filter})
+	}
+	for _, rule := range rules {
+		e, err := expr.Compile(rule)
+		if err != nil {
+			return nil, validator.IllegalArgument("FilterRules", err.Error())
+		}
+		filters = append(filters, &filterRule{
+			name: rule,
+			keep: func(entry *logrus.Entry) bool { return e.Eval(entryEnv{entry}) },
+		})
+	}
+	return filters, nil
+}
+
+// 表达式中的标识符:
+//   - level: 日志级别, 可与级别名称比较, 例如: level >= warn
+//   - msg, message: 日志内容
+//   - panic, fatal, error, warn, warning, info, debug, trace: 仅在与 level 比较时为日志级别常量,
+//     其他位置为同名的 entry.Data 字段, 例如: error == "timeout"
+//   - data.<key> 或 <key>: entry.Data 字段
+type entryEnv struct {
+	entry *logrus.Entry
+}
+
+func (e entryEnv) Lookup(name string) (interface{}, bool) {
+	switch name {
+	case "level":
+		return levelValue(e.entry.Level), true
+	case "msg", "message":
+		return e.entry.Message, true
+	}
+	if strings.HasPrefix(name, "data.") {
+		v, ok := e.entry.Data[name[len("data."):]]
+		return v, ok
+	}
+	v, ok := e.entry.Data[name]
+	return v, ok
+}
+
+// 按严重程度比较的日志级别, 例如: error > warn
+type levelValue logrus.Level
+
+func (l levelValue) Compare(other interface{}) (int, bool) {
+	var level logrus.Level
+	switch other := other.(type) {
+	case levelValue:
+		level = logrus.Level(other)
+	case string:
+		parsed, err := logrus.ParseLevel(other)
+		if err != nil {
+			return 0, false
+		}
+		level = parsed
+	default:
+		return 0, false
+	}
+	return int(level) - int(l), true
+}
+
+// 与 level 比较的标识符按级别名称解析, 例如: level >= warn
+func (l levelValue) Const(name string) (interface{}, bool) {
+	level, err := logrus.ParseLevel(name)
+	if err != nil {
+		return nil, false
+	}
+	return levelValue(level), true
+}
+
+func (l levelValue) String() string { return logrus.Level(l).String() }
+
+// 返回 false 时表示日志被某条规则丢弃
+func (h *Hook) filter(entry *logrus.Entry) bool {
+	for _, f := range h.filters {
+		if !f.keep(entry) {
+			atomic.AddInt64(&f.dropped, 1)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package slsh
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	const healthz = `level >= warn || path !~ "^/healthz"`
+
+	pushed := 0
+	hook := newTestHook(t, newMockService(func(ctx context.Context, message Message) error { pushed++; return nil }), func(c *Config) {
+		c.Filter = func(entry *logrus.Entry) bool { return entry.Data["drop"] == nil }
+		c.FilterRules = []string{healthz}
+	})
+	logger := newTestLogger(hook)
+
+	logger.WithField("path", "/healthz").Info("ok")
+	logger.WithField("path", "/healthz/ready").Debug("ok")
+	logger.WithField("path", "/healthz").Warn("slow")
+	logger.WithField("path", "/api").Info("hi")
+	logger.Info("no path")
+	logger.WithField("drop", true).Error("dropped")
+
+	assert.Equal(t, 3, pushed)
+	assert.Equal(t, map[string]int64{funcFilterName: 1, healthz: 2}, hook.Metrics().Filtered)
+
+	_, err := newFilterRules(nil, []string{"level >="})
+	assert.Error(t, err)
+}
+
+func TestEntryEnv(t *testing.T) {
+	entry := &logrus.Entry{
+		Level:   logrus.WarnLevel,
+		Message: "content",
+		Data:    logrus.Fields{"error": "e", "status": 500},
+	}
+
+	for rule, expected := range map[string]bool{
+		`level >= warn`:                    true,
+		`level >= warning`:                 true,
+		`level > warn`:                     false,
+		`level >= "info"`:                  true,
+		`level == "warn"`:                  true,
+		`level >= "bogus"`:                 false,
+		`level < error`:                    true,
+		`msg == "content"`:                 true,
+		`message =~ "^cont"`:               true,
+		`data.error == "e"`:                true,
+		`error == "e"`:                     true,
+		`error`:                            true,
+		`warn == nil`:                      true,
+		`level > error`:                    false,
+		`status >= 500`:                    true,
+		`data.status == 500`:               true,
+		`missing == nil`:                   true,
+		`level >= info && status`:          true,
+		`!(level <= debug)`:                true,
+		`level >= panic || level == fatal`: false,
+	} {
+		filters, err := newFilterRules(nil, []string{rule})
+		if assert.NoError(t, err, rule) {
+			assert.Equal(t, expected, filters[0].keep(entry), rule)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -14,11 +16,12 @@ import (
 )
 
 const (
-	DefaultBufferSize = 100
-	DefaultMessageKey = "message"
-	DefaultLevelKey   = "level"
-	DefaultTimeout    = 500 * time.Millisecond
-	DefaultInterval   = 3 * time.Second
+	DefaultBufferSize     = 100
+	DefaultMessageKey     = "message"
+	DefaultLevelKey       = "level"
+	DefaultTimeout        = 500 * time.Millisecond
+	DefaultInterval       = 3 * time.Second
+	DefaultRequestTimeout = 10 * time.Second
 )
 
 var (
@@ -45,24 +48,61 @@ type Config struct {
 	// 阿里云日志接入地址, 格式: "<region>.log.aliyuncs.com",
 	// 例如: "cn-hangzhou-intranet.log.aliyuncs.com",
 	// 更多接入点参考: https://help.aliyun.com/document_detail/29008.html?spm=a2c4g.11174283.6.1118.292a1caaVMpfPu
-	Endpoint        string
-	AccessKey       string            // 密钥对: key
-	AccessSecret    string            // 密钥对: secret
-	Project         string            // 日志项目名称
-	Store           string            // 日志库名称
-	Topic           string            // 日志 __topic__ 字段
-	Source          string            // 日志 __source__ 字段, 可选, 默认为 hostname
-	Extra           map[string]string // 日志附加字段, 可选
-	BufferSize      int               // 本地缓存日志条数, 可选, 默认为 100
-	Timeout         time.Duration     // 写缓存最大等待时间, 可选, 默认为 500ms
-	Interval        time.Duration     // 缓存刷新间隔, 可选, 默认为 3s
-	MessageKey      string            // 日志 Message 字段映射, 可选, 默认为 "message"
-	LevelKey        string            // 日志 Level 字段映射, 可选, 默认为 "level"
-	LevelMapping    LevelMapping      // 日志 Level 内容映射, 可选, 默认按照 syslog 规则映射
-	VisibleLevels   []logrus.Level    // 日志推送 Level, 可选, 默认推送 level >= info 的日志
-	HttpClient      *http.Client      // HTTP 客户端, 可选, 默认为 DefaultClient
-	ContentModifier ContentModifier   // 在发送前编辑日志内容, 可选, 默认为空
-	uri             *url.URL
+	Endpoint          string
+	AccessKey         string             // 密钥对: key
+	AccessSecret      string             // 密钥对: secret
+	Project           string             // 日志项目名称
+	Store             string             // 日志库名称
+	Topic             string             // 日志 __topic__ 字段
+	Source            string             // 日志 __source__ 字段, 可选, 默认由 SourceProvider 获取
+	SourceProvider    SourceProvider     // 日志 __source__ 字段来源, 可选, 默认为 HostnameSource(), 失败时回退为主机名, 例如: IPSource("eth0", false)
+	Extra             map[string]string  // 日志附加字段, 可选
+	LogTags           map[string]string  // 日志标签 (__tag__:<key>), 可选, 例如: DiscoverKubernetes(KubernetesOptions{})
+	ExtraPrefix       string             // 日志附加字段前缀, 可选, 默认为空
+	FieldPrefix       string             // entry.Data 字段前缀, 可选, 默认为空
+	SourceKey         string             // 将 Source 同时写入该字段, 可选, 默认为空
+	TimeKey           string             // 将 entry.Time 同时写入该字段, 可选, 默认为空
+	TimeFormat        TimeFormat         // TimeKey 字段格式, 可选, 默认为 RFC3339Nano
+	Formatter         logrus.Formatter   // 使用 Formatter 渲染 MessageKey 字段, 可选, 默认使用 entry.Message
+	FormatterOnly     bool               // 使用 Formatter 时不再写入 entry.Data 字段, 可选, 默认为 false
+	Enrichers         []Enricher         // 每条日志动态计算的附加字段, 可选, 例如: CachedEnricher(GoroutineEnricher("goroutines"), time.Second)
+	EnrichPolicy      EnrichPolicy       // Enricher 与 entry.Data 字段冲突时的取值规则, 可选, 默认 entry.Data 优先
+	FieldMap          map[string]string  // 字段重命名, 在 ContentModifier 之前执行, 可选, 例如: {"service": "service.name"}
+	Preset            Preset             // 预设字段映射, 可选, 例如: PresetOTel, PresetECS
+	BufferSize        int                // 本地缓存日志条数, 可选, 默认为 100
+	Timeout           time.Duration      // 写缓存最大等待时间, 可选, 默认为 500ms
+	RequestTimeout    time.Duration      // 单次写入请求超时, 可选, 默认为 10s
+	TimePolicy        TimePolicy         // 日志时间超出 [now - MaxAge, now + MaxFuture] 时的处理方式, 可选, 默认原样发送
+	MaxAge            time.Duration      // 日志时间下限, 应不超过 logstore 的保存时间, 可选, 默认为 7 天
+	MaxFuture         time.Duration      // 日志时间上限, 可选, 默认为 15m
+	UseEntryContext   bool               // 写缓存时以 entry.Context 为父 context, 请求取消后不再等待, 可选, 默认为 false
+	Interval          time.Duration      // 缓存刷新间隔, 可选, 默认为 3s
+	MinInterval       time.Duration      // 自适应刷新间隔下限, 与 MaxInterval 同时设置时按流量与写入耗时调整间隔, 可选
+	MaxInterval       time.Duration      // 自适应刷新间隔上限, 可选
+	Priority          *Priority          // 高优先级日志立即发送, 且不因 Timeout 丢弃, 可选, 默认不区分优先级
+	MessageKey        string             // 日志 Message 字段映射, 可选, 默认为 "message"
+	LevelKey          string             // 日志 Level 字段映射, 可选, 默认为 "level"
+	LevelMapping      LevelMapping       // 日志 Level 内容映射, 可选, 默认按照 syslog 规则映射
+	LevelRenderer     LevelRenderer      // 日志 Level 内容渲染, 可选, 设置后忽略 LevelMapping, 例如: UpperLevelRenderer
+	VisibleLevels     []logrus.Level     // 日志推送 Level, 可选, 默认推送 level >= info 的日志
+	Filter            EntryFilter        // 在转换前过滤日志, 返回 false 时丢弃, 可选, 默认为空
+	FilterRules       []string           // 过滤表达式, 任一结果为 false 时丢弃, 可选, 例如: `level >= warn || path !~ "^/healthz"`
+	Sampling          *Sampling          // 日志采样, 在过滤之后执行, 可选, 默认全部保留
+	Dedup             *Dedup             // 重复日志折叠, 在采样之后执行, 可选, 默认不折叠
+	RateLimits        *RateLimits        // 日志限流, 在折叠之后执行, 可选, 默认不限流
+	HttpClient        *http.Client       // HTTP 客户端, 可选, 默认为 NewHTTPClient(RequestTimeout)
+	ContentModifier   ContentModifier    // 在发送前编辑日志内容, 可选, 默认为空
+	Redactor          *Redactor          // 在 ContentModifier 之后对日志内容脱敏, 可选, 默认为空, 推荐使用 DefaultRedactRules 并设置 Salt
+	Encryptor         *Encryptor         // 在脱敏与字段值修正之后加密指定字段, 密文与字段名绑定, 已加密字段不再脱敏, 可选, 默认为空
+	KeySanitizer      *KeySanitizer      // 在脱敏与加密之前按日志服务规则修正字段名, 可选, 默认为空 (字段名原样发送)
+	ValueSanitizer    *ValueSanitizer    // 在加密之前修正非法 UTF-8, 控制字符以及过长的字段值, 可选, 默认为空 (字段值原样发送)
+	ErrorFields       *ErrorFields       // 将 error 字段展开为 message/type/stack/causes, 可选, 默认为空 (仅记录 err.Error())
+	CallerFields      *CallerFields      // 记录调用位置, 需开启 ReportCaller, 可选, 默认为空
+	TraceIDKey        string             // 日志 trace id 字段, 可选, 默认为 "trace_id"
+	SpanIDKey         string             // 日志 span id 字段, 可选, 默认为 "span_id"
+	ContextExtractors []ContextExtractor // 从 entry.Context 中提取字段, 可选, 默认提取 ContextWithTraceparent 保存的 trace id 与 span id
+	uri               *url.URL
+	filters           []*filterRule
 }
 
 func (c *Config) validate() (err error) {
@@ -77,24 +117,85 @@ func (c *Config) validate() (err error) {
 		return err
 	}
 
-	source, _ := os.Hostname()
-	c.Source = validator.CoalesceStr(c.Source, source)
+	if c.Preset != nil {
+		c.Preset(c)
+	}
+
+	if strings.TrimSpace(c.Source) == "" {
+		c.Source = c.source()
+	}
 	c.BufferSize = validator.CoalesceInt(c.BufferSize, DefaultBufferSize)
 	c.MessageKey = validator.CoalesceStr(c.MessageKey, DefaultMessageKey)
 	c.LevelKey = validator.CoalesceStr(c.LevelKey, DefaultLevelKey)
+	c.TraceIDKey = validator.CoalesceStr(c.TraceIDKey, DefaultTraceIDKey)
+	c.SpanIDKey = validator.CoalesceStr(c.SpanIDKey, DefaultSpanIDKey)
 	c.Timeout = validator.CoalesceDur(c.Timeout, DefaultTimeout)
 	c.Interval = validator.CoalesceDur(c.Interval, DefaultInterval)
+	c.RequestTimeout = validator.CoalesceDur(c.RequestTimeout, DefaultRequestTimeout)
+
+	if (c.MinInterval > 0) != (c.MaxInterval > 0) || c.MinInterval > c.MaxInterval {
+		return validator.IllegalArgument("MinInterval", "should be set with MaxInterval and not greater than it")
+	}
+
+	if c.MaxAge < 0 || c.MaxFuture < 0 {
+		return validator.IllegalArgument("MaxAge", "MaxAge and MaxFuture should not be negative")
+	}
 
 	if c.LevelMapping == nil {
 		c.LevelMapping = SyslogLevelMapping
 	}
 
+	if c.Redactor != nil {
+		if err := c.Redactor.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Encryptor != nil {
+		if err := c.Encryptor.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.ContextExtractors == nil {
+		c.ContextExtractors = []ContextExtractor{TraceparentExtractor(c.TraceIDKey, c.SpanIDKey)}
+	}
+
 	if c.VisibleLevels == nil {
 		c.VisibleLevels = DefaultVisibleLevels
 	}
 
 	if c.HttpClient == nil {
-		c.HttpClient = http.DefaultClient
+		c.HttpClient = NewHTTPClient(c.RequestTimeout)
+	}
+
+	if c.Priority != nil {
+		if err := c.Priority.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Sampling != nil {
+		if err := c.Sampling.validate(); err != nil {
+			return err
+		}
+		c.Sampling.extractors = c.ContextExtractors
+	}
+
+	if c.Dedup != nil {
+		if err := c.Dedup.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.RateLimits != nil {
+		if err := c.RateLimits.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.filters, err = newFilterRules(c.Filter, c.FilterRules); err != nil {
+		return err
 	}
 
 	c.uri, err = url.Parse(fmt.Sprintf(
@@ -105,12 +206,56 @@ func (c *Config) validate() (err error) {
 	return
 }
 
+// 从 SourceProvider 获取来源, 失败时回退为主机名, 主机名也获取失败时为空
+func (c *Config) source() string {
+	if c.SourceProvider != nil {
+		source, err := c.SourceProvider()
+		if err == nil {
+			return source
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Fail to get source: %v, fall back to hostname\n", err)
+	}
+	source, _ := HostnameSource()()
+	return source
+}
+
+func (c *Config) newConverter() *converter {
+	converter := NewConverter(c.MessageKey, c.LevelKey, c.LevelMapping, c.Extra, c.ContentModifier)
+	converter.LevelRenderer = c.LevelRenderer
+	converter.ExtraPrefix = c.ExtraPrefix
+	converter.FieldPrefix = c.FieldPrefix
+	converter.Source = c.Source
+	converter.SourceKey = c.SourceKey
+	converter.TimeKey = c.TimeKey
+	converter.TimeFormat = c.TimeFormat
+	converter.FieldMap = c.FieldMap
+	converter.Enrichers = c.Enrichers
+	converter.EnrichPolicy = c.EnrichPolicy
+	converter.Formatter = c.Formatter
+	converter.FormatterOnly = c.FormatterOnly
+	converter.ErrorFields = c.ErrorFields
+	converter.CallerFields = c.CallerFields
+	converter.Extractors = c.ContextExtractors
+	converter.Redactor = c.Redactor
+	converter.Encryptor = c.Encryptor
+	converter.KeySanitizer = c.KeySanitizer
+	converter.ValueSanitizer = c.ValueSanitizer
+	return converter
+}
+
 type Hook struct {
 	timeout       time.Duration
 	visibleLevels []logrus.Level
 	writer        Writer
 	converter     Converter
 	service       Service
+	metrics       *metrics
+	filters       []*filterRule
+	sampling      *Sampling
+	dedup         *deduplicator
+	limiter       *rateLimiter
+	priority      *Priority
+	entryContext  bool
 }
 
 func New(c Config) (*Hook, error) {
@@ -119,14 +264,47 @@ func New(c Config) (*Hook, error) {
 	}
 
 	writer := NewWriter(c.uri, c.Topic, c.Source, c.AccessKey, Secret(c.AccessSecret), c.HttpClient)
-	service := NewService(c.BufferSize, c.Interval, writer.WriteMessage)
-	converter := NewConverter(c.MessageKey, c.LevelKey, c.LevelMapping, c.Extra, c.ContentModifier)
+	writer.Tags = c.LogTags
+	writer.Timeout = c.RequestTimeout
+	writer.TimePolicy, writer.MaxAge, writer.MaxFuture = c.TimePolicy, c.MaxAge, c.MaxFuture
+	service := NewServiceContext(c.BufferSize, c.Interval, writer.WriteMessageContext)
+	service.MinInterval, service.MaxInterval = c.MinInterval, c.MaxInterval
+	if c.Priority != nil {
+		service.PriorityDelay = c.Priority.Delay
+	}
+	converter := c.newConverter()
 	hook := NewCustom(c.Timeout, c.VisibleLevels, converter, writer, service)
+	converter.metrics = hook.metrics
+	writer.metrics = hook.metrics
+	hook.configure(c)
 	return hook, nil
 }
 
+// 按已校验的 Config 设置过滤, 采样, 折叠, 限流与优先级
+func (h *Hook) configure(c Config) {
+	h.filters = c.filters
+	h.sampling = c.Sampling
+	h.priority = c.Priority
+	h.entryContext = c.UseEntryContext
+	if c.Dedup != nil {
+		h.dedup = newDeduplicator(*c.Dedup, h.metrics, h.send)
+		if r, ok := h.converter.(callerResolver); ok {
+			h.dedup.resolve = r.resolveCaller
+		}
+		h.dedup.Start()
+	}
+	if c.RateLimits != nil {
+		h.limiter = newRateLimiter(*c.RateLimits, h.metrics, h.send)
+		h.limiter.Start()
+	}
+}
+
+// service 为空时使用默认缓冲与间隔, 通过 writer 批量写入
 func NewCustom(timeout time.Duration, visibleLevels []logrus.Level,
 	converter Converter, writer Writer, service Service) *Hook {
+	if service == nil {
+		service = NewServiceContext(DefaultBufferSize, DefaultInterval, AdaptWriter(writer).WriteMessageContext)
+	}
 	go service.Start()
 
 	return &Hook{
@@ -135,6 +313,7 @@ func NewCustom(timeout time.Duration, visibleLevels []logrus.Level,
 		writer:        writer,
 		converter:     converter,
 		service:       service,
+		metrics:       &metrics{},
 	}
 }
 
@@ -145,10 +324,81 @@ func (h *Hook) Fire(entry *logrus.Entry) error {
 		}
 	}()
 
-	ctx, _ := context.WithTimeout(context.Background(), h.timeout)
-	return h.service.Push(ctx, h.converter.Message(entry))
+	if !mustDeliverFromContext(entry.Context) {
+		return h.fire(entry)
+	}
+	return h.push(entry, 1)
+}
+
+// 依次执行过滤, 采样, 折叠与限流
+func (h *Hook) fire(entry *logrus.Entry) error {
+	if !h.filter(entry) {
+		return nil
+	}
+
+	rate := 1.0
+	if h.sampling != nil {
+		var keep bool
+		if rate, keep = h.sampling.sample(entry); !keep {
+			atomic.AddInt64(&h.metrics.Sampled, 1)
+			return nil
+		}
+	}
+
+	if h.dedup != nil && !h.dedup.allow(entry, rate) {
+		return nil
+	}
+
+	if h.limiter != nil && !h.limiter.allow(entry) {
+		return nil
+	}
+
+	return h.push(entry, rate)
+}
+
+func (h *Hook) push(entry *logrus.Entry, rate float64) error {
+	message := h.converter.Message(entry)
+	if h.sampling != nil {
+		h.sampling.mark(message, rate)
+	}
+	if store := storeFromContext(entry.Context); store != "" {
+		message.Store = store
+	}
+
+	if ps, ok := h.service.(PriorityService); ok && h.isPriority(entry) {
+		return ps.PushPriority(context.Background(), message)
+	}
+	if mustDeliverFromContext(entry.Context) {
+		return h.service.Push(context.Background(), message)
+	}
+
+	parent := context.Background()
+	if h.entryContext && entry.Context != nil {
+		parent = entry.Context
+	}
+	ctx, cancel := context.WithTimeout(parent, h.timeout)
+	defer cancel()
+	return h.service.Push(ctx, message)
 }
 
-func (h *Hook) Levels() []logrus.Level                 { return h.visibleLevels }
-func (h *Hook) Close() error                           { return h.CloseContext(context.Background()) }
-func (h *Hook) CloseContext(ctx context.Context) error { return h.service.Stop(ctx) }
+// 发送汇总日志, 原请求可能已结束, 仅保留 entry.Context 中的值
+func (h *Hook) send(entry *logrus.Entry, rate float64) {
+	if entry.Context != nil {
+		entry.Context = detachedContext{entry.Context}
+	}
+	if err := h.push(entry, rate); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Fail to push logs: %v\n", err)
+	}
+}
+
+func (h *Hook) Levels() []logrus.Level { return h.visibleLevels }
+func (h *Hook) Close() error           { return h.CloseContext(context.Background()) }
+func (h *Hook) CloseContext(ctx context.Context) error {
+	if h.dedup != nil {
+		h.dedup.Stop()
+	}
+	if h.limiter != nil {
+		h.limiter.Stop()
+	}
+	return h.service.Stop(ctx)
+}
@@ -3,14 +3,22 @@ package slsh
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/pierrec/lz4"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/api"
 )
 
 func ExampleHook() {
@@ -86,6 +94,18 @@ func TestConfig(t *testing.T) {
 		c = raw
 		c.Topic = " "
 		assert.Error(t, c.validate())
+
+		c = raw
+		c.MinInterval = time.Second
+		assert.Error(t, c.validate())
+
+		c = raw
+		c.MinInterval, c.MaxInterval = 2*time.Second, time.Second
+		assert.Error(t, c.validate())
+
+		c = raw
+		c.MaxAge = -time.Second
+		assert.Error(t, c.validate())
 	})
 
 	t.Run("default", func(t *testing.T) {
@@ -119,6 +139,11 @@ func TestConfig(t *testing.T) {
 			assert.Equal(t, DefaultInterval, c.Interval)
 		}
 
+		c = raw
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, DefaultRequestTimeout, c.RequestTimeout)
+		}
+
 		c = raw
 		c.MessageKey = ""
 		if assert.NoError(t, c.validate()) {
@@ -145,10 +170,92 @@ func TestConfig(t *testing.T) {
 
 		c = raw
 		c.HttpClient = nil
+		c.RequestTimeout = 3 * time.Second
 		if assert.NoError(t, c.validate()) {
-			assert.Equal(t, http.DefaultClient, c.HttpClient)
+			assert.NotEqual(t, http.DefaultClient, c.HttpClient)
+			if transport, ok := c.HttpClient.Transport.(*http.Transport); assert.True(t, ok) {
+				assert.Equal(t, c.RequestTimeout, transport.ResponseHeaderTimeout)
+			}
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	var mu sync.Mutex
+	logs := make(map[string][]map[string]string)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		group, err := decodeLogGroup(req)
+		if !assert.NoError(t, err) {
+			return nil, err
 		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, log := range group.Logs {
+			contents := make(map[string]string)
+			for _, content := range log.Contents {
+				contents[content.GetKey()] = content.GetValue()
+			}
+			logs[req.URL.Path] = append(logs[req.URL.Path], contents)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
 	})
+
+	c := testConfig(nil)
+	c.HttpClient = &http.Client{Transport: transport}
+	c.Interval = time.Hour
+	c.FilterRules = []string{`path != "/healthz"`}
+	c.Dedup = &Dedup{Window: time.Hour}
+	c.Priority = &Priority{Level: logrus.ErrorLevel}
+	hook, err := New(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	logger := newTestLogger(hook)
+	logger.WithField("path", "/healthz").Info("probe")
+	for i := 0; i < 3; i++ {
+		logger.Error("boom")
+	}
+	logger.WithContext(WithStore(context.Background(), "audit")).Info("login")
+	assert.NoError(t, hook.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if store := logs["/logstores/test-store/shards/lb"]; assert.Len(t, store, 2) {
+		assert.Equal(t, "boom", store[0]["message"])
+		assert.NotContains(t, store[0], RepeatCountKey)
+		assert.Equal(t, "boom", store[1]["message"])
+		assert.Equal(t, "2", store[1][RepeatCountKey])
+		assert.Equal(t, "demo", store[1]["service.name"])
+	}
+	if store := logs["/logstores/audit/shards/lb"]; assert.Len(t, store, 1) {
+		assert.Equal(t, "login", store[0]["message"])
+	}
+
+	m := hook.Metrics()
+	assert.Equal(t, map[string]int64{c.FilterRules[0]: 1}, m.Filtered)
+	assert.Equal(t, int64(2), m.Deduplicated)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func decodeLogGroup(req *http.Request) (*api.LogGroup, error) {
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.Atoi(req.Header.Get("X-Log-Bodyrawsize"))
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, size)
+	if _, err = lz4.UncompressBlock(data, raw); err != nil {
+		return nil, err
+	}
+	group := &api.LogGroup{}
+	return group, proto.Unmarshal(raw, group)
 }
 
 func TestHook(t *testing.T) {
@@ -199,13 +306,32 @@ func TestHook(t *testing.T) {
 
 		logger.Info("Hi")
 
-		ctx, _ := context.WithTimeout(context.TODO(), time.Second)
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+		defer cancel()
 		err := hook.CloseContext(ctx)
 		assert.NoError(t, err)
 
 		assert.Len(t, ops, 5)
 	})
 
+	t.Run("default service", func(t *testing.T) {
+		var written []Message
+		writer := &MockWriter{
+			onWriteMessage: func(messages ...Message) error {
+				written = append(written, messages...)
+				return nil
+			},
+		}
+
+		hook := NewCustom(DefaultTimeout, DefaultVisibleLevels, messageConverter, writer, nil)
+		newTestLogger(hook).Info("Hi")
+		assert.NoError(t, hook.Close())
+
+		if assert.Len(t, written, 1) {
+			assert.Equal(t, "Hi", written[0].Contents["message"])
+		}
+	})
+
 	t.Run("panic", func(t *testing.T) {
 		counter := 0
 		writer := &MockWriter{
@@ -240,6 +366,15 @@ func (s MockService) Push(ctx context.Context, message Message) error { return s
 func (s MockService) Start()                                          { s.onStart() }
 func (s MockService) Stop(ctx context.Context) error                  { return s.onStop(ctx) }
 
+type MockPriorityService struct {
+	MockService
+	onPushPriority func(ctx context.Context, message Message) error
+}
+
+func (s MockPriorityService) PushPriority(ctx context.Context, message Message) error {
+	return s.onPushPriority(ctx, message)
+}
+
 type MockWriter struct {
 	onWriteMessage func(messages ...Message) error
 }
@@ -251,3 +386,59 @@ type MockConverter struct {
 }
 
 func (c MockConverter) Message(entry *logrus.Entry) Message { return c.onMessage(entry) }
+
+func testConfig(preset Preset) Config {
+	return Config{
+		Endpoint:     "regionid.example.com",
+		AccessKey:    "123",
+		AccessSecret: "321",
+		Project:      "test-project",
+		Store:        "test-store",
+		Topic:        "test-topic",
+		Source:       "10.0.0.1",
+		Extra:        map[string]string{"service.name": "demo"},
+		Preset:       preset,
+	}
+}
+
+// 以 testConfig 为基础, 经 configure 修改并校验后创建使用 service 的 Hook
+func newTestHook(t *testing.T, service Service, configure func(c *Config)) *Hook {
+	c := testConfig(nil)
+	if configure != nil {
+		configure(&c)
+	}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	hook := NewCustom(DefaultTimeout, logrus.AllLevels, messageConverter, nil, service)
+	hook.configure(c)
+	return hook
+}
+
+func newTestLogger(hook *Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.SetLevel(logrus.TraceLevel)
+	logger.AddHook(hook)
+	return logger
+}
+
+func newMockService(onPush func(ctx context.Context, message Message) error) *MockService {
+	return &MockService{
+		onPush:  onPush,
+		onStart: func() {},
+		onStop:  func(ctx context.Context) error { return nil },
+	}
+}
+
+func newMockPriorityService(onPush, onPushPriority func(ctx context.Context, message Message) error) *MockPriorityService {
+	return &MockPriorityService{MockService: *newMockService(onPush), onPushPriority: onPushPriority}
+}
+
+// 仅输出 entry.Message 的 Converter
+var messageConverter = &MockConverter{
+	onMessage: func(entry *logrus.Entry) Message {
+		return Message{Contents: map[string]string{"message": entry.Message}}
+	},
+}
@@ -0,0 +1,455 @@
+// Package expr 实现用于过滤日志的简单表达式, 例如:
+//
+//	level >= warn || path !~ "^/healthz"
+//
+// 支持 ||, &&, !, 括号, 比较运算 == != > >= < <=, 正则匹配 =~ !~ (右侧必须为字符串字面量),
+// 字符串 (双引号) 与数字字面量, 以及由 Env 解析的标识符 (可包含 "." 与 "-").
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 标识符取值
+type Env interface {
+	Lookup(name string) (interface{}, bool)
+}
+
+type EnvFunc func(name string) (interface{}, bool)
+
+func (f EnvFunc) Lookup(name string) (interface{}, bool) { return f(name) }
+
+// 自定义比较, 例如日志级别, 返回 a 与 other 比较结果 (-1, 0, 1), 无法比较时 ok 为 false
+type Comparer interface {
+	Compare(other interface{}) (result int, ok bool)
+}
+
+// 可选, 由 Comparer 实现: 比较运算另一侧的标识符优先按名称解析为常量, 例如日志级别 "warn",
+// 解析失败时再由 Env 取值. 其他位置的同名标识符始终由 Env 取值
+type ConstResolver interface {
+	Const(name string) (interface{}, bool)
+}
+
+type Expr struct {
+	src  string
+	root node
+}
+
+func (e *Expr) String() string { return e.src }
+
+// 求值, 结果按真值规则转换为 bool
+func (e *Expr) Eval(env Env) bool { return truthy(e.root.eval(env)) }
+
+func Compile(src string) (*Expr, error) {
+	p := &parser{lexer: lexer{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+func MustCompile(src string) *Expr {
+	e, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// ---- lexer ----
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+var operators = []string{"||", "&&", "==", "!=", ">=", "<=", "=~", "!~", ">", "<", "!"}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}, nil
+	case c == '"':
+		for l.pos++; l.pos < len(l.src) && l.src[l.pos] != '"'; l.pos++ {
+			if l.src[l.pos] == '\\' {
+				l.pos++
+			}
+		}
+		if l.pos >= len(l.src) {
+			return token{}, fmt.Errorf("unterminated string at %d", start)
+		}
+		l.pos++
+		s, err := strconv.Unquote(l.src[start:l.pos])
+		if err != nil {
+			return token{}, fmt.Errorf("invalid string at %d: %v", start, err)
+		}
+		return token{kind: tokString, text: s, pos: start}, nil
+	case '0' <= c && c <= '9' || c == '-' && l.pos+1 < len(l.src) && '0' <= l.src[l.pos+1] && l.src[l.pos+1] <= '9':
+		for l.pos++; l.pos < len(l.src) && strings.IndexByte("0123456789.eE", l.src[l.pos]) >= 0; l.pos++ {
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+	case isIdentStart(c):
+		for l.pos++; l.pos < len(l.src) && isIdentPart(l.src[l.pos]); l.pos++ {
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected %q at %d", c, start)
+}
+
+func isIdentStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '@'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || '0' <= c && c <= '9' || c == '.' || c == '-'
+}
+
+// ---- parser ----
+
+type parser struct {
+	lexer lexer
+	tok   token
+	err   error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	if p.tok, p.err = p.lexer.next(); p.err != nil {
+		p.tok = token{kind: tokEOF}
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("%s at %d", fmt.Sprintf(format, args...), p.tok.pos)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.tok.kind == tokOp && p.tok.text == "||" {
+		p.next()
+		var right node
+		if right, err = p.parseAnd(); err == nil {
+			left = orNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.tok.kind == tokOp && p.tok.text == "&&" {
+		p.next()
+		var right node
+		if right, err = p.parseUnary(); err == nil {
+			left = andNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.tok.kind == tokOp && p.tok.text == "!" {
+		p.next()
+		n, err := p.parseUnary()
+		return notNode{n}, err
+	}
+	if p.tok.kind == tokLParen {
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expect )")
+		}
+		p.next()
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil || p.tok.kind != tokOp {
+		return left, err
+	}
+
+	op := p.tok.text
+	switch op {
+	case "==", "!=", ">", ">=", "<", "<=":
+		p.next()
+		right, err := p.parseOperand()
+		return compareNode{op, left, right}, err
+	case "=~", "!~":
+		p.next()
+		if p.tok.kind != tokString {
+			return nil, p.errorf("expect regexp string after %s", op)
+		}
+		re, err := regexp.Compile(p.tok.text)
+		if err != nil {
+			return nil, p.errorf("invalid regexp: %v", err)
+		}
+		p.next()
+		return matchNode{op == "!~", left, re}, nil
+	default:
+		return left, nil
+	}
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokIdent:
+		p.next()
+		switch tok.text {
+		case "true", "false":
+			return literal{tok.text == "true"}, nil
+		case "nil", "null":
+			return literal{nil}, nil
+		}
+		return ident(tok.text), nil
+	case tokString:
+		p.next()
+		return literal{tok.text}, nil
+	case tokNumber:
+		p.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", tok.text, tok.pos)
+		}
+		return literal{f}, nil
+	default:
+		return nil, p.errorf("unexpected %q", tok.text)
+	}
+}
+
+// ---- evaluation ----
+
+type node interface {
+	eval(env Env) interface{}
+}
+
+type literal struct{ v interface{} }
+
+func (n literal) eval(Env) interface{} { return n.v }
+
+type ident string
+
+func (n ident) eval(env Env) interface{} {
+	v, _ := env.Lookup(string(n))
+	return v
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(env Env) interface{} {
+	return truthy(n.left.eval(env)) || truthy(n.right.eval(env))
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(env Env) interface{} {
+	return truthy(n.left.eval(env)) && truthy(n.right.eval(env))
+}
+
+type notNode struct{ n node }
+
+func (n notNode) eval(env Env) interface{} { return !truthy(n.n.eval(env)) }
+
+type matchNode struct {
+	not  bool
+	left node
+	re   *regexp.Regexp
+}
+
+func (n matchNode) eval(env Env) interface{} {
+	return n.re.MatchString(toString(n.left.eval(env))) != n.not
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(env Env) interface{} {
+	a, b := n.left.eval(env), n.right.eval(env)
+	result, ok := compare(resolveConst(n.left, a, b), resolveConst(n.right, b, a))
+	if !ok {
+		return n.op == "!="
+	}
+	switch n.op {
+	case "==":
+		return result == 0
+	case "!=":
+		return result != 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	default:
+		return result <= 0
+	}
+}
+
+// 当 other 实现 ConstResolver 时, 按名称解析标识符 n, 否则返回 v
+func resolveConst(n node, v, other interface{}) interface{} {
+	name, ok := n.(ident)
+	if !ok {
+		return v
+	}
+	if r, ok := other.(ConstResolver); ok {
+		if c, ok := r.Const(string(name)); ok {
+			return c
+		}
+	}
+	return v
+}
+
+func compare(a, b interface{}) (int, bool) {
+	if c, ok := a.(Comparer); ok {
+		return c.Compare(b)
+	}
+	if c, ok := b.(Comparer); ok {
+		result, ok := c.Compare(a)
+		return -result, ok
+	}
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0, true
+		}
+		return 0, false
+	}
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	return strings.Compare(toString(a), toString(b)), true
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	default:
+		if f, ok := toNumber(v); ok {
+			return f != 0
+		}
+		return true
+	}
+}
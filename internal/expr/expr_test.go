@@ -0,0 +1,132 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type severity int
+
+func (s severity) Compare(other interface{}) (int, bool) {
+	o, ok := other.(severity)
+	if !ok {
+		return 0, false
+	}
+	return int(s) - int(o), true
+}
+
+type rank int
+
+func (r rank) Compare(other interface{}) (int, bool) {
+	o, ok := other.(rank)
+	if !ok {
+		return 0, false
+	}
+	return int(r) - int(o), true
+}
+
+func (r rank) Const(name string) (interface{}, bool) {
+	c, ok := map[string]rank{"low": 1, "high": 2}[name]
+	return c, ok
+}
+
+func TestCompile(t *testing.T) {
+	for _, src := range []string{
+		`a`,
+		`!a`,
+		`a == "b"`,
+		`a.b-c >= 1.5 && (x != nil || y =~ "^/h")`,
+		`level >= warn || path !~ "^/healthz"`,
+		`n < -1`,
+	} {
+		e, err := Compile(src)
+		if assert.NoError(t, err, src) {
+			assert.Equal(t, src, e.String())
+		}
+	}
+
+	for _, src := range []string{
+		``,
+		`a ==`,
+		`(a`,
+		`a b`,
+		`a =~ b`,
+		`a =~ "("`,
+		`"unterminated`,
+		`a == $`,
+		`a == 1.2.3`,
+	} {
+		_, err := Compile(src)
+		assert.Error(t, err, src)
+	}
+
+	assert.Panics(t, func() { MustCompile(`(`) })
+}
+
+func TestEval(t *testing.T) {
+	env := EnvFunc(func(name string) (interface{}, bool) {
+		v, ok := map[string]interface{}{
+			"level":  severity(3),
+			"warn":   severity(3),
+			"error":  severity(4),
+			"path":   "/healthz/live",
+			"status": 200,
+			"ok":     true,
+			"empty":  "",
+		}[name]
+		return v, ok
+	})
+
+	for src, expected := range map[string]bool{
+		`level >= warn`:                         true,
+		`level >= error`:                        false,
+		`level < error`:                         true,
+		`level == "warn"`:                       false,
+		`level != "warn"`:                       true,
+		`path =~ "^/healthz"`:                   true,
+		`path !~ "^/healthz"`:                   false,
+		`level >= error || path !~ "^/healthz"`: false,
+		`level >= warn && path =~ "live$"`:      true,
+		`status == 200`:                         true,
+		`status >= "500"`:                       false,
+		`status > 199.5 && status < 300`:        true,
+		`path > "/a"`:                           true,
+		`ok`:                                    true,
+		`!ok`:                                   false,
+		`ok == true`:                            true,
+		`empty`:                                 false,
+		`missing`:                               false,
+		`missing == nil`:                        true,
+		`missing != "x"`:                        true,
+		`missing !~ "x"`:                        true,
+		`!(status == 200 && ok)`:                false,
+		`status`:                                true,
+		`0`:                                     false,
+	} {
+		assert.Equal(t, expected, MustCompile(src).Eval(env), src)
+	}
+}
+
+func TestConstResolver(t *testing.T) {
+	env := EnvFunc(func(name string) (interface{}, bool) {
+		v, ok := map[string]interface{}{
+			"rank": rank(2),
+			"high": "field",
+			"peer": rank(1),
+		}[name]
+		return v, ok
+	})
+
+	for src, expected := range map[string]bool{
+		`rank == high`:    true,
+		`rank > low`:      true,
+		`low < rank`:      true,
+		`rank > peer`:     true,
+		`high == "field"`: true,
+		`high`:            true,
+		`rank == "high"`:  false,
+	} {
+		assert.Equal(t, expected, MustCompile(src).Eval(env), src)
+	}
+}
@@ -0,0 +1,112 @@
+package slsh
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+	// cgroup v2 下 /proc/self/cgroup 仅为 "0::/", 需从 hostname 等挂载点的源路径中获取,
+	// 例如: "/var/lib/docker/containers/<id>/hostname", "/overlay-containers/<id>/userdata/hostname".
+	// containerd 的 "/sandboxes/<id>/" 为 pause 容器, 不匹配
+	mountContainerIDPattern = regexp.MustCompile(`/(?:containers|overlay-containers)/([0-9a-f]{64})/`)
+)
+
+// Kubernetes 元数据发现配置
+type KubernetesOptions struct {
+	Root       string              // 文件系统根目录, 可选, 默认为 "/", 测试时可指向伪造的目录
+	PodInfoDir string              // Downward API 卷挂载目录, 可选, 默认为 "/etc/podinfo"
+	Getenv     func(string) string // 环境变量读取, 可选, 默认为 os.Getenv
+}
+
+// 从 Downward API 环境变量与文件, service account 以及 cgroup 中发现 Kubernetes 元数据:
+//
+//	环境变量: POD_NAME, POD_NAMESPACE, POD_IP, NODE_NAME, CONTAINER_NAME, CONTAINER_IMAGE
+//	文件: <PodInfoDir>/labels, <PodInfoDir>/annotations,
+//	      /var/run/secrets/kubernetes.io/serviceaccount/namespace,
+//	      /proc/self/cgroup, /proc/self/mountinfo (cgroup v2)
+//
+// 字段名参考 OpenTelemetry 语义约定, 例如: "k8s.pod.name", "k8s.pod.label.app", "container.id".
+// 结果可直接作为 Config.LogTags 或 Config.Extra 使用.
+func DiscoverKubernetes(opts KubernetesOptions) map[string]string {
+	root := opts.Root
+	if root == "" {
+		root = "/"
+	}
+	podInfo := opts.PodInfoDir
+	if podInfo == "" {
+		podInfo = "/etc/podinfo"
+	}
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	read := func(name string) []byte {
+		data, _ := ioutil.ReadFile(filepath.Join(root, name))
+		return data
+	}
+
+	meta := make(map[string]string)
+	for env, key := range map[string]string{
+		"POD_NAME":        "k8s.pod.name",
+		"POD_NAMESPACE":   "k8s.namespace.name",
+		"POD_IP":          "k8s.pod.ip",
+		"NODE_NAME":       "k8s.node.name",
+		"CONTAINER_NAME":  "k8s.container.name",
+		"CONTAINER_IMAGE": "container.image.name",
+	} {
+		if v := strings.TrimSpace(getenv(env)); v != "" {
+			meta[key] = v
+		}
+	}
+
+	if _, ok := meta["k8s.namespace.name"]; !ok {
+		if ns := strings.TrimSpace(string(read("/var/run/secrets/kubernetes.io/serviceaccount/namespace"))); ns != "" {
+			meta["k8s.namespace.name"] = ns
+		}
+	}
+
+	for k, v := range parsePodInfo(read(filepath.Join(podInfo, "labels"))) {
+		meta["k8s.pod.label."+k] = v
+	}
+	for k, v := range parsePodInfo(read(filepath.Join(podInfo, "annotations"))) {
+		meta["k8s.pod.annotation."+k] = v
+	}
+
+	if id := containerIDPattern.Find(read("/proc/self/cgroup")); id != nil {
+		meta["container.id"] = string(id)
+	} else if m := mountContainerIDPattern.FindSubmatch(read("/proc/self/mountinfo")); m != nil {
+		meta["container.id"] = string(m[1])
+	}
+	return meta
+}
+
+// Kubernetes 元数据 Enricher, 仅在首次使用时发现一次
+func KubernetesEnricher(opts KubernetesOptions) Enricher {
+	return CachedEnricher(EnricherFunc(func() map[string]string { return DiscoverKubernetes(opts) }), 0)
+}
+
+// 解析 Downward API 文件, 每行格式: key="value"
+func parsePodInfo(data []byte) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			continue
+		}
+		v, err := strconv.Unquote(strings.TrimSpace(kv[1]))
+		if err != nil {
+			v = strings.TrimSpace(kv[1])
+		}
+		fields[strings.TrimSpace(kv[0])] = v
+	}
+	return fields
+}
@@ -0,0 +1,70 @@
+package slsh
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testContainerID = "8e3a36a1c5b8f4c6d3d0e4c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2"
+
+func TestDiscoverKubernetes(t *testing.T) {
+	root, err := ioutil.TempDir("", "k8s")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(root) }()
+
+	files := map[string]string{
+		"etc/podinfo/labels":                                     "app=\"demo\"\npod-template-hash=\"5d4f\"\ninvalid\n",
+		"etc/podinfo/annotations":                                "note=\"a \\\"quoted\\\" value\"\n",
+		"var/run/secrets/kubernetes.io/serviceaccount/namespace": "prod\n",
+		"proc/self/cgroup":                                       "12:pids:/kubepods/burstable/pod1234/" + testContainerID + "\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755)) ||
+			!assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644)) {
+			return
+		}
+	}
+
+	env := map[string]string{"POD_NAME": "demo-5d4f-abcde", "NODE_NAME": "node-1", "CONTAINER_IMAGE": "demo:1.0"}
+	opts := KubernetesOptions{Root: root, Getenv: func(key string) string { return env[key] }}
+
+	expected := map[string]string{
+		"k8s.pod.name":                    "demo-5d4f-abcde",
+		"k8s.node.name":                   "node-1",
+		"container.image.name":            "demo:1.0",
+		"k8s.namespace.name":              "prod",
+		"k8s.pod.label.app":               "demo",
+		"k8s.pod.label.pod-template-hash": "5d4f",
+		"k8s.pod.annotation.note":         `a "quoted" value`,
+		"container.id":                    testContainerID,
+	}
+	assert.Equal(t, expected, DiscoverKubernetes(opts))
+	assert.Equal(t, expected, KubernetesEnricher(opts).Enrich())
+
+	env["POD_NAMESPACE"] = "staging"
+	assert.Equal(t, "staging", DiscoverKubernetes(opts)["k8s.namespace.name"])
+
+	sandboxID := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	files = map[string]string{
+		"proc/self/cgroup": "0::/\n",
+		"proc/self/mountinfo": "1180 1179 0:118 / / rw,relatime - overlay overlay rw\n" +
+			"1196 1180 254:1 /var/lib/containerd/io.containerd.grpc.v1.cri/sandboxes/" + sandboxID + "/hostname /etc/hostname rw - ext4 /dev/vda1 rw\n" +
+			"1197 1180 254:1 /var/lib/docker/containers/" + testContainerID + "/resolv.conf /etc/resolv.conf rw - ext4 /dev/vda1 rw\n",
+	}
+	for name, content := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644)) {
+			return
+		}
+	}
+	assert.Equal(t, testContainerID, DiscoverKubernetes(opts)["container.id"])
+
+	empty := KubernetesOptions{Root: filepath.Join(root, "missing"), Getenv: func(string) string { return "" }}
+	assert.Empty(t, DiscoverKubernetes(empty))
+}
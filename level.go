@@ -0,0 +1,64 @@
+package slsh
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 日志级别渲染, 将 level 写入 contents[key], 可以写入多个字段
+type LevelRenderer interface {
+	Render(contents map[string]string, key string, level logrus.Level)
+}
+
+type LevelRendererFunc func(contents map[string]string, key string, level logrus.Level)
+
+func (f LevelRendererFunc) Render(contents map[string]string, key string, level logrus.Level) {
+	f(contents, key, level)
+}
+
+var (
+	// 小写级别名称, 例如: "error", "warning"
+	LowerLevelRenderer = LevelRendererFunc(func(contents map[string]string, key string, level logrus.Level) {
+		contents[key] = level.String()
+	})
+	// 大写级别名称, 例如: "ERROR", "WARNING"
+	UpperLevelRenderer = LevelRendererFunc(func(contents map[string]string, key string, level logrus.Level) {
+		contents[key] = strings.ToUpper(level.String())
+	})
+)
+
+// 按 LevelMapping 输出数字级别, 例如: SyslogLevelMapping
+func MappingLevelRenderer(mapping LevelMapping) LevelRenderer {
+	return LevelRendererFunc(func(contents map[string]string, key string, level logrus.Level) {
+		contents[key] = strconv.Itoa(mapping(level))
+	})
+}
+
+// OpenTelemetry SeverityText 与 SeverityNumber, 参考:
+// https://opentelemetry.io/docs/specs/otel/logs/data-model/#severity-fields
+var otelSeverities = [...]struct {
+	text   string
+	number int
+}{
+	logrus.PanicLevel: {"FATAL", 24},
+	logrus.FatalLevel: {"FATAL", 21},
+	logrus.ErrorLevel: {"ERROR", 17},
+	logrus.WarnLevel:  {"WARN", 13},
+	logrus.InfoLevel:  {"INFO", 9},
+	logrus.DebugLevel: {"DEBUG", 5},
+	logrus.TraceLevel: {"TRACE", 1},
+}
+
+// 将 SeverityText 写入 key, SeverityNumber 写入 numberKey
+func OTelLevelRenderer(numberKey string) LevelRenderer {
+	return LevelRendererFunc(func(contents map[string]string, key string, level logrus.Level) {
+		if int(level) >= len(otelSeverities) {
+			level = logrus.TraceLevel
+		}
+		severity := otelSeverities[level]
+		contents[key] = severity.text
+		contents[numberKey] = strconv.Itoa(severity.number)
+	})
+}
@@ -0,0 +1,42 @@
+package slsh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelRenderer(t *testing.T) {
+	render := func(r LevelRenderer, level logrus.Level) map[string]string {
+		contents := make(map[string]string)
+		r.Render(contents, "level", level)
+		return contents
+	}
+
+	assert.Equal(t, map[string]string{"level": "warning"}, render(LowerLevelRenderer, logrus.WarnLevel))
+	assert.Equal(t, map[string]string{"level": "ERROR"}, render(UpperLevelRenderer, logrus.ErrorLevel))
+	assert.Equal(t, map[string]string{"level": "3"}, render(MappingLevelRenderer(SyslogLevelMapping), logrus.ErrorLevel))
+
+	otel := OTelLevelRenderer("severity_number")
+	for level, expected := range map[logrus.Level][2]string{
+		logrus.PanicLevel: {"FATAL", "24"},
+		logrus.FatalLevel: {"FATAL", "21"},
+		logrus.ErrorLevel: {"ERROR", "17"},
+		logrus.WarnLevel:  {"WARN", "13"},
+		logrus.InfoLevel:  {"INFO", "9"},
+		logrus.DebugLevel: {"DEBUG", "5"},
+		logrus.TraceLevel: {"TRACE", "1"},
+	} {
+		assert.Equal(t, map[string]string{"level": expected[0], "severity_number": expected[1]}, render(otel, level))
+	}
+
+	t.Run("converter", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.LevelRenderer = UpperLevelRenderer
+
+		msg := c.Message(&logrus.Entry{Time: time.Now(), Level: logrus.InfoLevel})
+		assert.Equal(t, "INFO", msg.Contents["level"])
+	})
+}
@@ -0,0 +1,53 @@
+package slsh
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Hook 运行指标
+type Metrics struct {
+	InvalidUTF8Values int64 // 含非法 UTF-8 而被修正的字段值数
+	EscapedValues     int64 // 含控制字符而被转义的字段值数
+	TruncatedValues   int64 // 超出长度而被截断的字段值数
+	Sampled           int64 // 被采样丢弃的日志数
+	Deduplicated      int64 // 被折叠的重复日志数
+	RateLimited       int64 // 被限流丢弃的日志数
+	ClampedTimes      int64 // 时间超出范围而被替换为当前时间的日志数
+	DroppedTimes      int64 // 时间超出范围而被丢弃的日志数
+
+	ClockOffset time.Duration // 服务端与本地时钟的偏差, 请求签名时用于校正 Date
+
+	Filtered map[string]int64 // 各过滤规则丢弃的日志数, Config.Filter 对应的规则名为 "Filter"
+}
+
+// 各组件共享的计数器, 需使用 atomic 读写
+type metrics struct {
+	Metrics
+}
+
+func (m *metrics) Snapshot() Metrics {
+	return Metrics{
+		InvalidUTF8Values: atomic.LoadInt64(&m.InvalidUTF8Values),
+		EscapedValues:     atomic.LoadInt64(&m.EscapedValues),
+		TruncatedValues:   atomic.LoadInt64(&m.TruncatedValues),
+		Sampled:           atomic.LoadInt64(&m.Sampled),
+		Deduplicated:      atomic.LoadInt64(&m.Deduplicated),
+		RateLimited:       atomic.LoadInt64(&m.RateLimited),
+		ClampedTimes:      atomic.LoadInt64(&m.ClampedTimes),
+		DroppedTimes:      atomic.LoadInt64(&m.DroppedTimes),
+		ClockOffset:       time.Duration(atomic.LoadInt64((*int64)(&m.ClockOffset))),
+	}
+}
+
+// 当前运行指标快照
+func (h *Hook) Metrics() Metrics {
+	m := h.metrics.Snapshot()
+	if len(h.filters) > 0 {
+		m.Filtered = make(map[string]int64, len(h.filters))
+		for _, f := range h.filters {
+			m.Filtered[f.name] = atomic.LoadInt64(&f.dropped)
+		}
+	}
+	return m
+}
@@ -0,0 +1,85 @@
+package slsh
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+// 时间字段格式
+type TimeFormat func(t time.Time) string
+
+var (
+	RFC3339NanoTimeFormat TimeFormat = func(t time.Time) string { return t.Format(time.RFC3339Nano) }
+	UnixNanoTimeFormat    TimeFormat = func(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
+)
+
+// 预设字段映射, 在 Config 校验前调用, 仅修改未设置的字段
+type Preset func(c *Config)
+
+// OpenTelemetry Logs 数据模型, 参考: https://opentelemetry.io/docs/specs/otel/logs/data-model/
+//
+//	Body, SeverityText, SeverityNumber, Timestamp, TraceId, SpanId,
+//	Resource.* (Config.Extra 与 Source), Attributes.* (entry.Data)
+var PresetOTel Preset = func(c *Config) {
+	c.MessageKey = validator.CoalesceStr(c.MessageKey, "Body")
+	c.LevelKey = validator.CoalesceStr(c.LevelKey, "SeverityText")
+	if c.LevelRenderer == nil {
+		c.LevelRenderer = OTelLevelRenderer("SeverityNumber")
+	}
+	c.TimeKey = validator.CoalesceStr(c.TimeKey, "Timestamp")
+	if c.TimeFormat == nil {
+		c.TimeFormat = UnixNanoTimeFormat
+	}
+	c.TraceIDKey = validator.CoalesceStr(c.TraceIDKey, "TraceId")
+	c.SpanIDKey = validator.CoalesceStr(c.SpanIDKey, "SpanId")
+	c.ExtraPrefix = validator.CoalesceStr(c.ExtraPrefix, "Resource.")
+	c.SourceKey = validator.CoalesceStr(c.SourceKey, "Resource.host.name")
+	c.FieldPrefix = validator.CoalesceStr(c.FieldPrefix, "Attributes.")
+}
+
+// Elastic Common Schema, 参考: https://www.elastic.co/guide/en/ecs/current/ecs-field-reference.html
+//
+//	@timestamp, log.level, message, error.*, service.name, host.hostname, log.origin.*
+var PresetECS Preset = func(c *Config) {
+	c.MessageKey = validator.CoalesceStr(c.MessageKey, "message")
+	c.LevelKey = validator.CoalesceStr(c.LevelKey, "log.level")
+	if c.LevelRenderer == nil {
+		c.LevelRenderer = LowerLevelRenderer
+	}
+	c.TimeKey = validator.CoalesceStr(c.TimeKey, "@timestamp")
+	if c.TimeFormat == nil {
+		c.TimeFormat = RFC3339NanoTimeFormat
+	}
+	c.TraceIDKey = validator.CoalesceStr(c.TraceIDKey, "trace.id")
+	c.SpanIDKey = validator.CoalesceStr(c.SpanIDKey, "span.id")
+	c.SourceKey = validator.CoalesceStr(c.SourceKey, "host.hostname")
+	if c.ErrorFields == nil {
+		c.ErrorFields = &ErrorFields{}
+	}
+	if c.CallerFields == nil {
+		c.CallerFields = &CallerFields{
+			FileKey: "log.origin.file.name",
+			LineKey: "log.origin.file.line",
+			FuncKey: "log.origin.function",
+			Path:    CallerPathBase,
+		}
+	}
+	c.FieldMap = mergeFieldMap(c.FieldMap, map[string]string{
+		"service":     "service.name",
+		"error.stack": "error.stack_trace",
+	})
+}
+
+// 合并字段映射, 已存在的映射优先
+func mergeFieldMap(fieldMap, defaults map[string]string) map[string]string {
+	merged := make(map[string]string, len(fieldMap)+len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range fieldMap {
+		merged[k] = v
+	}
+	return merged
+}
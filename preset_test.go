@@ -0,0 +1,104 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresetOTel(t *testing.T) {
+	c := testConfig(PresetOTel)
+	c.ErrorFields = &ErrorFields{}
+	if !assert.NoError(t, c.validate()) {
+		return
+	}
+
+	entry := logrus.NewEntry(logrus.New()).
+		WithContext(ContextWithTraceparent(context.TODO(), testTraceparent)).
+		WithTime(time.Unix(1577836800, 123).UTC()).
+		WithFields(logrus.Fields{"user": "u1", logrus.ErrorKey: errors.New("e")})
+	entry.Level = logrus.WarnLevel
+	entry.Message = "content"
+
+	msg := c.newConverter().Message(entry)
+	assert.Equal(t, map[string]string{
+		"Body":                     "content",
+		"SeverityText":             "WARN",
+		"SeverityNumber":           "13",
+		"Timestamp":                strconv.FormatInt(entry.Time.UnixNano(), 10),
+		"TraceId":                  testTraceID,
+		"SpanId":                   testSpanID,
+		"Resource.service.name":    "demo",
+		"Resource.host.name":       "10.0.0.1",
+		"Attributes.user":          "u1",
+		"Attributes.error.message": "e",
+		"Attributes.error.type":    "*errors.errorString",
+	}, msg.Contents)
+
+	t.Run("override", func(t *testing.T) {
+		c := testConfig(PresetOTel)
+		c.MessageKey = "body"
+		c.TimeFormat = RFC3339NanoTimeFormat
+		if assert.NoError(t, c.validate()) {
+			msg := c.newConverter().Message(entry)
+			assert.Equal(t, "content", msg.Contents["body"])
+			assert.Equal(t, "2020-01-01T00:00:00.000000123Z", msg.Contents["Timestamp"])
+		}
+	})
+}
+
+func TestPresetECS(t *testing.T) {
+	c := testConfig(PresetECS)
+	c.Extra = map[string]string{"service": "demo"}
+	c.FieldMap = map[string]string{"user": "user.id"}
+	if !assert.NoError(t, c.validate()) {
+		return
+	}
+
+	logger := logrus.New()
+	entry := logrus.NewEntry(logger).
+		WithTime(time.Unix(1577836800, 0).UTC()).
+		WithFields(logrus.Fields{"user": "u1", logrus.ErrorKey: stackError{msg: "e", stack: fakeStack{"main.a"}}})
+	entry.Level = logrus.ErrorLevel
+	entry.Message = "content"
+
+	msg := c.newConverter().Message(entry)
+	assert.Equal(t, map[string]string{
+		"message":           "content",
+		"log.level":         "error",
+		"@timestamp":        "2020-01-01T00:00:00Z",
+		"host.hostname":     "10.0.0.1",
+		"service.name":      "demo",
+		"user.id":           "u1",
+		"error.message":     "e",
+		"error.type":        "slsh.stackError",
+		"error.stack_trace": "main.a",
+	}, msg.Contents)
+
+	t.Run("caller", func(t *testing.T) {
+		logger.SetReportCaller(true)
+		entry.Caller = &runtime.Frame{File: "/src/main.go", Line: 10, Function: "main.main"}
+
+		msg := c.newConverter().Message(entry)
+		assert.Equal(t, "main.go", msg.Contents["log.origin.file.name"])
+		assert.Equal(t, "10", msg.Contents["log.origin.file.line"])
+		assert.Equal(t, "main.main", msg.Contents["log.origin.function"])
+	})
+}
+
+func TestFieldMap(t *testing.T) {
+	c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+	c.FieldMap = map[string]string{"a": "b", "b": "c", "missing": "d"}
+
+	msg := c.Message(&logrus.Entry{Data: logrus.Fields{"a": "1", "b": "2"}, Time: time.Now()})
+	assert.Equal(t, "1", msg.Contents["b"])
+	assert.Equal(t, "2", msg.Contents["c"])
+	assert.NotContains(t, msg.Contents, "a")
+	assert.NotContains(t, msg.Contents, "d")
+}
@@ -0,0 +1,32 @@
+package slsh
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+// 高优先级日志, 不受 Timeout 限制, 通过独立队列在 Delay 内发送
+type Priority struct {
+	Level logrus.Level  // 优先级阈值, 该级别及以上的日志为高优先级, 例如: logrus.ErrorLevel
+	Delay time.Duration // 最长等待时间, 可选, 默认立即发送
+}
+
+func (p *Priority) validate() error {
+	if p.Delay < 0 {
+		return validator.IllegalArgument("Priority", "delay should not be negative")
+	}
+	return nil
+}
+
+// 支持高优先级队列的 Service
+type PriorityService interface {
+	PushPriority(ctx context.Context, message Message) error
+}
+
+func (h *Hook) isPriority(entry *logrus.Entry) bool {
+	return h.priority != nil && entry.Level <= h.priority.Level || priorityFromContext(entry.Context)
+}
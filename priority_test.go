@@ -0,0 +1,39 @@
+package slsh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriority(t *testing.T) {
+	var normal, priority []string
+	service := newMockPriorityService(func(ctx context.Context, message Message) error {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		normal = append(normal, message.Contents["message"])
+		return nil
+	}, func(ctx context.Context, message Message) error {
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+		priority = append(priority, message.Contents["message"])
+		return nil
+	})
+	hook := newTestHook(t, service, func(c *Config) { c.Priority = &Priority{Level: logrus.ErrorLevel} })
+	logger := newTestLogger(hook)
+
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+
+	assert.Equal(t, []string{"info", "warn"}, normal)
+	assert.Equal(t, []string{"error"}, priority)
+
+	t.Run("validate", func(t *testing.T) {
+		assert.NoError(t, (&Priority{Level: logrus.ErrorLevel, Delay: time.Millisecond}).validate())
+		assert.Error(t, (&Priority{Delay: -time.Millisecond}).validate())
+	})
+}
@@ -0,0 +1,245 @@
+package slsh
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+const (
+	DefaultRateLimitMaxKeys  = 1000
+	DefaultRateLimitInterval = time.Minute
+
+	RateLimitedKey = "rate_limited"
+)
+
+// 令牌桶限流参数
+type RateLimit struct {
+	Rate  float64 // 每秒允许的日志数
+	Burst int     // 突发容量, 可选, 默认为 Rate 向上取整
+}
+
+func (r RateLimit) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return math.Max(1, math.Ceil(r.Rate))
+}
+
+// 日志限流, 超出限制的日志被丢弃, 每个 Interval 最多发送一条 "rate limited N entries" 通知
+type RateLimits struct {
+	Levels   map[logrus.Level]RateLimit // 各级别限流, 可选
+	Key      string                     // 按字段值限流, 例如: "component", 可选
+	PerKey   RateLimit                  // 每个字段值的限流, 与 Key 同时设置
+	MaxKeys  int                        // 跟踪的最大字段值数, 超出后新字段值共享同一限流, 可选, 默认为 1000
+	Interval time.Duration              // 通知间隔, 可选, 默认为 1m
+	now      func() time.Time
+}
+
+func (r *RateLimits) validate() error {
+	for level, limit := range r.Levels {
+		if limit.Rate <= 0 || limit.Burst < 0 {
+			return validator.IllegalArgument("RateLimits", fmt.Sprintf("invalid limit of %s", level))
+		}
+	}
+	if r.Key != "" && (r.PerKey.Rate <= 0 || r.PerKey.Burst < 0) {
+		return validator.IllegalArgument("RateLimits", "invalid limit of key "+r.Key)
+	}
+	r.MaxKeys = validator.CoalesceInt(r.MaxKeys, DefaultRateLimitMaxKeys)
+	r.Interval = validator.CoalesceDur(r.Interval, DefaultRateLimitInterval)
+	return nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// 按 limit 补充令牌, 返回是否至少有一个令牌
+func (b *bucket) ready(limit RateLimit, now time.Time) bool {
+	burst := limit.burst()
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*limit.Rate)
+	}
+	b.last = now
+	return b.tokens >= 1
+}
+
+// 桶已补满, 可以释放
+func (b *bucket) idle(limit RateLimit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= limit.burst()
+}
+
+type rateLimiter struct {
+	RateLimits
+	notify   func(entry *logrus.Entry, rate float64)
+	metrics  *metrics
+	mu       sync.Mutex
+	levels   map[logrus.Level]*bucket
+	keys     map[string]*bucket
+	overflow bucket
+	dropped  int64
+	notified time.Time
+	chQuit   chan struct{}
+	onClose  sync.Once
+	wg       sync.WaitGroup
+}
+
+func newRateLimiter(r RateLimits, m *metrics, notify func(entry *logrus.Entry, rate float64)) *rateLimiter {
+	if r.now == nil {
+		r.now = time.Now
+	}
+	levels := make(map[logrus.Level]*bucket, len(r.Levels))
+	for level := range r.Levels {
+		levels[level] = &bucket{}
+	}
+	return &rateLimiter{
+		RateLimits: r,
+		notify:     notify,
+		metrics:    m,
+		levels:     levels,
+		keys:       make(map[string]*bucket),
+		notified:   r.now(),
+		chQuit:     make(chan struct{}),
+	}
+}
+
+// 返回 false 时日志被限流
+func (l *rateLimiter) allow(entry *logrus.Entry) bool {
+	now := l.now()
+
+	l.mu.Lock()
+	allowed := l.take(entry, now)
+	if !allowed {
+		l.dropped++
+	}
+	notice := l.notice(now, false)
+	l.mu.Unlock()
+
+	if !allowed {
+		atomic.AddInt64(&l.metrics.RateLimited, 1)
+	}
+	if notice != nil {
+		notice.Logger = entry.Logger
+		l.notify(notice, 1)
+	}
+	return allowed
+}
+
+// 级别与字段值的令牌都足够时才同时取出, 避免被一方拒绝的日志消耗另一方的令牌
+func (l *rateLimiter) take(entry *logrus.Entry, now time.Time) bool {
+	level, ok := l.levels[entry.Level]
+	if ok && !level.ready(l.Levels[entry.Level], now) {
+		return false
+	}
+	key := l.keyBucket(entry, now)
+	if key != nil && !key.ready(l.PerKey, now) {
+		return false
+	}
+	if level != nil {
+		level.tokens--
+	}
+	if key != nil {
+		key.tokens--
+	}
+	return true
+}
+
+// 返回 entry 字段值对应的桶, 未设置 Key 或字段不存在时返回 nil
+func (l *rateLimiter) keyBucket(entry *logrus.Entry, now time.Time) *bucket {
+	if l.Key == "" {
+		return nil
+	}
+	v, ok := entry.Data[l.Key]
+	if !ok {
+		return nil
+	}
+
+	key := fmt.Sprint(v)
+	b, ok := l.keys[key]
+	if !ok {
+		if len(l.keys) >= l.MaxKeys {
+			l.evict(now)
+		}
+		if len(l.keys) >= l.MaxKeys {
+			return &l.overflow
+		}
+		b = &bucket{}
+		l.keys[key] = b
+	}
+	return b
+}
+
+// 释放已补满的桶, 需持有锁
+func (l *rateLimiter) evict(now time.Time) {
+	for key, b := range l.keys {
+		if b.idle(l.PerKey, now) {
+			delete(l.keys, key)
+		}
+	}
+}
+
+// 生成限流通知, 需持有锁
+func (l *rateLimiter) notice(now time.Time, force bool) *logrus.Entry {
+	if l.dropped == 0 || !force && now.Sub(l.notified) < l.Interval {
+		return nil
+	}
+	dropped := l.dropped
+	l.dropped, l.notified = 0, now
+	return &logrus.Entry{
+		Time:    now,
+		Level:   logrus.WarnLevel,
+		Message: fmt.Sprintf("rate limited %d entries", dropped),
+		Data:    logrus.Fields{RateLimitedKey: dropped},
+	}
+}
+
+// 发送限流通知, force 为 true 时立即发送未通知的限流数
+func (l *rateLimiter) flush(force bool) {
+	l.mu.Lock()
+	notice := l.notice(l.now(), force)
+	l.mu.Unlock()
+
+	if notice != nil {
+		l.notify(notice, 1)
+	}
+}
+
+// 启动定时通知, 保证限流后没有新日志时也能发送通知
+func (l *rateLimiter) Start() {
+	l.wg.Add(1)
+	go l.loop()
+}
+
+func (l *rateLimiter) loop() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.flush(false)
+		case <-l.chQuit:
+			return
+		}
+	}
+}
+
+// 停止定时通知, 并立即发送未通知的限流数
+func (l *rateLimiter) Stop() {
+	l.onClose.Do(func() {
+		close(l.chQuit)
+		l.wg.Wait()
+		l.flush(true)
+	})
+}
@@ -0,0 +1,143 @@
+package slsh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimits(t *testing.T) {
+	now := time.Unix(1577836800, 0)
+	var notices []*logrus.Entry
+	l := newRateLimiter(RateLimits{
+		Levels:   map[logrus.Level]RateLimit{logrus.InfoLevel: {Rate: 1, Burst: 3}},
+		Key:      "component",
+		PerKey:   RateLimit{Rate: 1},
+		MaxKeys:  2,
+		Interval: time.Minute,
+		now:      func() time.Time { return now },
+	}, &metrics{}, func(entry *logrus.Entry, rate float64) { notices = append(notices, entry) })
+
+	info := &logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{}}
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.allow(info))
+	}
+	assert.False(t, l.allow(info))
+	now = now.Add(time.Second)
+	assert.True(t, l.allow(info))
+	assert.False(t, l.allow(info))
+
+	t.Run("key", func(t *testing.T) {
+		entry := func(component string) *logrus.Entry {
+			return &logrus.Entry{Level: logrus.WarnLevel, Data: logrus.Fields{"component": component}}
+		}
+		assert.True(t, l.allow(entry("a")))
+		assert.False(t, l.allow(entry("a")))
+		assert.True(t, l.allow(entry("b")))
+		assert.True(t, l.allow(entry("c")))
+		assert.False(t, l.allow(entry("d")))
+		assert.Len(t, l.keys, 2)
+
+		now = now.Add(time.Second)
+		assert.True(t, l.allow(entry("d")))
+		assert.Len(t, l.keys, 1)
+	})
+
+	t.Run("notice", func(t *testing.T) {
+		assert.Empty(t, notices)
+		assert.Equal(t, int64(4), l.metrics.RateLimited)
+
+		now = now.Add(time.Minute)
+		assert.True(t, l.allow(info))
+		if assert.Len(t, notices, 1) {
+			assert.Equal(t, logrus.WarnLevel, notices[0].Level)
+			assert.Equal(t, "rate limited 4 entries", notices[0].Message)
+			assert.Equal(t, int64(4), notices[0].Data[RateLimitedKey])
+		}
+
+		assert.True(t, l.allow(info))
+		assert.True(t, l.allow(info))
+		assert.False(t, l.allow(info))
+		l.flush(false)
+		assert.Len(t, notices, 1)
+		l.flush(true)
+		if assert.Len(t, notices, 2) {
+			assert.Equal(t, "rate limited 1 entries", notices[1].Message)
+		}
+		l.flush(true)
+		assert.Len(t, notices, 2)
+	})
+
+	t.Run("both buckets", func(t *testing.T) {
+		l := newRateLimiter(RateLimits{
+			Levels:  map[logrus.Level]RateLimit{logrus.InfoLevel: {Rate: 1, Burst: 2}},
+			Key:     "component",
+			PerKey:  RateLimit{Rate: 1},
+			MaxKeys: 10,
+			now:     func() time.Time { return now },
+		}, &metrics{}, func(*logrus.Entry, float64) {})
+
+		entry := func(component string) *logrus.Entry {
+			return &logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{"component": component}}
+		}
+		assert.True(t, l.allow(entry("a")))
+		assert.False(t, l.allow(entry("a")))
+		assert.True(t, l.allow(entry("b")))
+		assert.False(t, l.allow(entry("c")))
+	})
+
+	t.Run("ticker", func(t *testing.T) {
+		chNotice := make(chan *logrus.Entry, 1)
+		l := newRateLimiter(RateLimits{
+			Levels:   map[logrus.Level]RateLimit{logrus.InfoLevel: {Rate: 0.001, Burst: 1}},
+			Interval: 10 * time.Millisecond,
+		}, &metrics{}, func(entry *logrus.Entry, rate float64) { chNotice <- entry })
+		l.Start()
+
+		assert.True(t, l.allow(info))
+		assert.False(t, l.allow(info))
+		select {
+		case notice := <-chNotice:
+			assert.Equal(t, "rate limited 1 entries", notice.Message)
+		case <-time.After(time.Second):
+			assert.Fail(t, "notice not sent")
+		}
+
+		assert.False(t, l.allow(info))
+		l.Stop()
+		select {
+		case notice := <-chNotice:
+			assert.Equal(t, "rate limited 1 entries", notice.Message)
+		default:
+			assert.Fail(t, "notice not sent on stop")
+		}
+	})
+
+	assert.Error(t, (&RateLimits{Levels: map[logrus.Level]RateLimit{logrus.InfoLevel: {}}}).validate())
+	assert.Error(t, (&RateLimits{Key: "component"}).validate())
+}
+
+func TestHookRateLimits(t *testing.T) {
+	var pushed []string
+	hook := newTestHook(t, newMockService(func(ctx context.Context, message Message) error {
+		pushed = append(pushed, message.Contents["message"])
+		return nil
+	}), func(c *Config) {
+		c.RateLimits = &RateLimits{
+			Levels:   map[logrus.Level]RateLimit{logrus.ErrorLevel: {Rate: 0.001, Burst: 2}},
+			Interval: time.Hour,
+		}
+	})
+	logger := newTestLogger(hook)
+
+	for i := 0; i < 10; i++ {
+		logger.Error("boom")
+	}
+	logger.Info("ok")
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, []string{"boom", "boom", "ok", "rate limited 8 entries"}, pushed)
+	assert.Equal(t, int64(8), hook.Metrics().RateLimited)
+}
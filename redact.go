@@ -0,0 +1,165 @@
+package slsh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+// 脱敏动作
+type RedactAction int
+
+const (
+	RedactDrop     RedactAction = iota // 删除整个字段
+	RedactMask                         // 除末尾 Keep 个字符外全部替换为 '*'
+	RedactHash                         // 替换为加盐 sha256 摘要, 仅在同一个 Salt 下可比对, 未设置 Redactor.Salt 时按 RedactMask 处理
+	RedactTruncate                     // 仅保留前 Keep 个字符
+)
+
+// 脱敏规则, Keys 与 Pattern 至少设置一项:
+//   - 仅设置 Keys: 对字段名匹配的整个值执行 Action
+//   - 仅设置 Pattern: 对所有字段值中匹配的片段执行 Action
+//   - 同时设置: 仅对字段名匹配的值中匹配的片段执行 Action
+//
+// RedactDrop 总是删除整个字段.
+type RedactRule struct {
+	Name    string         // 规则名称, 可选
+	Keys    []string       // 字段名 glob, 不区分大小写, 例如: "*password*"
+	Pattern *regexp.Regexp // 字段值正则
+	Action  RedactAction   // 脱敏动作
+	Keep    int            // RedactMask 保留的末尾字符数, 或 RedactTruncate 保留的开头字符数
+}
+
+// 默认脱敏规则: 密钥类字段, Bearer token, 身份证号, 手机号, 邮箱
+//
+// 邮箱使用 RedactHash, 需要同时设置 Redactor.Salt, 否则邮箱被完全掩码.
+var DefaultRedactRules = []RedactRule{
+	{
+		Name:   "secret",
+		Keys:   []string{"*password*", "*passwd*", "*secret*", "*token*", "*authorization*", "*cookie*", "*access_key*", "*accesskey*"},
+		Action: RedactMask,
+	},
+	{
+		Name:    "bearer",
+		Pattern: regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`),
+		Action:  RedactMask,
+	},
+	{
+		Name:    "id_card",
+		Pattern: regexp.MustCompile(`\b[1-9]\d{16}[\dXx]\b`),
+		Action:  RedactMask,
+		Keep:    4,
+	},
+	{
+		Name:    "phone",
+		Pattern: regexp.MustCompile(`\b1[3-9]\d{9}\b`),
+		Action:  RedactMask,
+		Keep:    4,
+	},
+	{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`),
+		Action:  RedactHash,
+	},
+}
+
+// 按规则对日志内容脱敏, 可作为 ContentModifier 使用
+type Redactor struct {
+	Rules []RedactRule
+	Salt  []byte // RedactHash 使用的盐, 存在 RedactHash 规则时必填, 更换后摘要无法与之前的比对
+}
+
+func (r *Redactor) validate() error {
+	for _, rule := range r.Rules {
+		if len(rule.Keys) == 0 && rule.Pattern == nil {
+			return validator.IllegalArgument("Redactor", "rule "+rule.Name+" requires keys or pattern")
+		}
+		for _, key := range rule.Keys {
+			if _, err := path.Match(key, ""); err != nil {
+				return validator.IllegalArgument("Redactor", "rule "+rule.Name+" key "+key+" "+err.Error())
+			}
+		}
+		if rule.Action == RedactHash && len(r.Salt) == 0 {
+			return validator.IllegalArgument("Redactor", "rule "+rule.Name+" requires salt")
+		}
+	}
+	return nil
+}
+
+func (r *Redactor) Modify(contents map[string]string) { r.modify(contents, nil) }
+
+// 跳过 skip 返回 true 的字段, 例如已加密的字段
+func (r *Redactor) modify(contents map[string]string, skip func(key string) bool) {
+	for k, v := range contents {
+		if skip != nil && skip(k) {
+			continue
+		}
+		if v, ok := r.redact(k, v); ok {
+			contents[k] = v
+		} else {
+			delete(contents, k)
+		}
+	}
+}
+
+func (r *Redactor) redact(key, value string) (string, bool) {
+	for _, rule := range r.Rules {
+		if len(rule.Keys) > 0 && !matchKey(rule.Keys, key) {
+			continue
+		}
+		if rule.Pattern == nil {
+			if rule.Action == RedactDrop {
+				return "", false
+			}
+			value = r.apply(rule, value)
+			continue
+		}
+		if !rule.Pattern.MatchString(value) {
+			continue
+		}
+		if rule.Action == RedactDrop {
+			return "", false
+		}
+		value = rule.Pattern.ReplaceAllStringFunc(value, func(s string) string { return r.apply(rule, s) })
+	}
+	return value, true
+}
+
+func (r *Redactor) apply(rule RedactRule, value string) string {
+	switch rule.Action {
+	case RedactMask:
+		runes := []rune(value)
+		for i := 0; i < len(runes)-rule.Keep; i++ {
+			runes[i] = '*'
+		}
+		return string(runes)
+	case RedactHash:
+		if len(r.Salt) == 0 {
+			// 未加盐的摘要可通过字典反查, 退化为掩码
+			return r.apply(RedactRule{Action: RedactMask, Keep: rule.Keep}, value)
+		}
+		sum := sha256.Sum256(append(append([]byte{}, r.Salt...), value...))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	case RedactTruncate:
+		if runes := []rune(value); len(runes) > rule.Keep {
+			return string(runes[:rule.Keep])
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+func matchKey(patterns []string, key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), key); ok {
+			return true
+		}
+	}
+	return false
+}
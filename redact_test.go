@@ -0,0 +1,110 @@
+package slsh
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor(t *testing.T) {
+	t.Run("actions", func(t *testing.T) {
+		r := &Redactor{
+			Salt: []byte("salt"),
+			Rules: []RedactRule{
+				{Keys: []string{"drop"}, Action: RedactDrop},
+				{Keys: []string{"mask_*"}, Action: RedactMask, Keep: 2},
+				{Keys: []string{"hash"}, Action: RedactHash},
+				{Keys: []string{"TRUNCATE"}, Action: RedactTruncate, Keep: 3},
+				{Keys: []string{"code"}, Pattern: regexp.MustCompile(`\d+`), Action: RedactMask},
+				{Pattern: regexp.MustCompile(`forbidden`), Action: RedactDrop},
+			},
+		}
+		contents := map[string]string{
+			"drop":     "v",
+			"mask_1":   "abcdef",
+			"hash":     "abcdef",
+			"truncate": "中文字段",
+			"code":     "a12b345",
+			"other":    "a12b345",
+			"bad":      "some forbidden word",
+		}
+		r.Modify(contents)
+
+		assert.Equal(t, map[string]string{
+			"mask_1":   "****ef",
+			"hash":     r.apply(RedactRule{Action: RedactHash}, "abcdef"),
+			"truncate": "中文字",
+			"code":     "a**b***",
+			"other":    "a12b345",
+		}, contents)
+		assert.Regexp(t, `^sha256:[0-9a-f]{16}$`, contents["hash"])
+		assert.NotEqual(t, contents["hash"], (&Redactor{Salt: []byte("pepper")}).apply(RedactRule{Action: RedactHash}, "abcdef"))
+		assert.Equal(t, "****ef", (&Redactor{}).apply(RedactRule{Action: RedactHash, Keep: 2}, "abcdef"))
+	})
+
+	t.Run("default rules", func(t *testing.T) {
+		r := &Redactor{Rules: DefaultRedactRules, Salt: []byte("salt")}
+		contents := map[string]string{
+			"user_password": "p@ss",
+			"Authorization": "Bearer abc.def-ghi",
+			"header":        "Authorization: Bearer abc.def-ghi",
+			"phone":         "call 13812345678 now",
+			"id":            "110101199003071234",
+			"email":         "mail to someone@example.com",
+			"order":         "20200101123456789012",
+		}
+		r.Modify(contents)
+
+		assert.Equal(t, "****", contents["user_password"])
+		assert.Equal(t, "******************", contents["Authorization"])
+		assert.Equal(t, "Authorization: ******************", contents["header"])
+		assert.Equal(t, "call *******5678 now", contents["phone"])
+		assert.Equal(t, "**************1234", contents["id"])
+		assert.Regexp(t, `^mail to sha256:[0-9a-f]{16}$`, contents["email"])
+		assert.Equal(t, "20200101123456789012", contents["order"])
+	})
+
+	t.Run("unsalted", func(t *testing.T) {
+		contents := map[string]string{"email": "mail to someone@example.com"}
+		(&Redactor{Rules: DefaultRedactRules}).Modify(contents)
+		assert.Equal(t, "mail to *******************", contents["email"])
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		assert.NoError(t, (&Redactor{Rules: DefaultRedactRules, Salt: []byte("salt")}).validate())
+		assert.Error(t, (&Redactor{Rules: DefaultRedactRules}).validate())
+		assert.NoError(t, (&Redactor{Rules: []RedactRule{{Keys: []string{"*"}, Action: RedactMask}}}).validate())
+		assert.Error(t, (&Redactor{Rules: []RedactRule{{Action: RedactDrop}}}).validate())
+		assert.Error(t, (&Redactor{Rules: []RedactRule{{Keys: []string{"["}}}}).validate())
+	})
+
+	t.Run("never reach writer", func(t *testing.T) {
+		secrets := []string{"p@ssw0rd", "13812345678", "someone@example.com", "eyJhbGciOiJIUzI1NiJ9"}
+
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.Redactor = &Redactor{Rules: DefaultRedactRules, Salt: []byte("salt")}
+		entry := &logrus.Entry{
+			Data: logrus.Fields{
+				"password": secrets[0],
+				"mobile":   secrets[1],
+				"headers":  map[string]string{"Authorization": "Bearer " + secrets[3]},
+			},
+			Time:    time.Now(),
+			Level:   logrus.InfoLevel,
+			Message: "register " + secrets[2],
+		}
+
+		w := NewWriter(&url.URL{}, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, nil)
+		raw, err := w.encode(c.Message(entry))
+		if assert.NoError(t, err) {
+			for _, secret := range secrets {
+				assert.False(t, bytes.Contains(raw, []byte(secret)), secret)
+			}
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package slsh
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+const DefaultSampleRateKey = "sample_rate"
+
+// 日志采样配置, Warn 及以上级别的日志总是保留
+type Sampling struct {
+	Rates   map[logrus.Level]float64 // 各级别采样率, 取值 (0, 1], 可选, 未设置的级别全部保留
+	Key     string                   // 一致性采样字段, 例如: "trace_id", 相同取值的日志同时保留或丢弃, entry.Data 中不存在时由 Config.ContextExtractors 提取, 可选, 默认随机采样
+	RateKey string                   // 在保留的日志中记录采样率, 用于统计时加权, 可选, 默认为 "sample_rate"
+	random  func() float64
+
+	extractors []ContextExtractor
+}
+
+func (s *Sampling) validate() error {
+	for level, rate := range s.Rates {
+		if rate <= 0 || rate > 1 {
+			return validator.IllegalArgument("Sampling", fmt.Sprintf("rate of %s should be in (0, 1]", level))
+		}
+	}
+	s.RateKey = validator.CoalesceStr(s.RateKey, DefaultSampleRateKey)
+	return nil
+}
+
+// 返回日志所在级别的采样率, 以及是否保留
+func (s *Sampling) sample(entry *logrus.Entry) (float64, bool) {
+	rate, ok := s.Rates[entry.Level]
+	if !ok || rate >= 1 || entry.Level <= logrus.WarnLevel {
+		return 1, true
+	}
+
+	if v, ok := s.value(entry); ok {
+		h := fnv.New64a()
+		_, _ = fmt.Fprint(h, v)
+		return rate, float64(mix64(h.Sum64())>>11)/(1<<53) < rate
+	}
+
+	random := s.random
+	if random == nil {
+		random = rand.Float64
+	}
+	return rate, random() < rate
+}
+
+// 一致性采样字段的取值, 优先读取 entry.Data, 其次从 entry.Context 中提取, 例如: trace_id
+func (s *Sampling) value(entry *logrus.Entry) (interface{}, bool) {
+	if s.Key == "" {
+		return nil, false
+	}
+	if v, ok := entry.Data[s.Key]; ok {
+		return v, true
+	}
+	if entry.Context == nil || len(s.extractors) == 0 {
+		return nil, false
+	}
+	contents := make(map[string]string)
+	for _, extractor := range s.extractors {
+		extractor.Extract(entry.Context, contents)
+	}
+	v, ok := contents[s.Key]
+	return v, ok
+}
+
+func (s *Sampling) mark(message Message, rate float64) {
+	if rate < 1 && message.Contents != nil {
+		message.Contents[validator.CoalesceStr(s.RateKey, DefaultSampleRateKey)] = strconv.FormatFloat(rate, 'g', -1, 64)
+	}
+}
+
+// FNV 高位分布不均匀 (例如: 连续数字的 ID), 使用 murmur3 finalizer 打散
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,116 @@
+package slsh
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampling(t *testing.T) {
+	s := &Sampling{Rates: map[logrus.Level]float64{
+		logrus.InfoLevel:  0.5,
+		logrus.WarnLevel:  0.1,
+		logrus.DebugLevel: 1,
+	}}
+	if !assert.NoError(t, s.validate()) {
+		return
+	}
+	assert.Equal(t, DefaultSampleRateKey, s.RateKey)
+
+	t.Run("level", func(t *testing.T) {
+		s.random = func() float64 { return 0.9 }
+		rate, keep := s.sample(&logrus.Entry{Level: logrus.WarnLevel})
+		assert.True(t, keep)
+		assert.Equal(t, 1.0, rate)
+
+		_, keep = s.sample(&logrus.Entry{Level: logrus.DebugLevel})
+		assert.True(t, keep)
+		_, keep = s.sample(&logrus.Entry{Level: logrus.TraceLevel})
+		assert.True(t, keep)
+
+		rate, keep = s.sample(&logrus.Entry{Level: logrus.InfoLevel})
+		assert.False(t, keep)
+		assert.Equal(t, 0.5, rate)
+
+		s.random = func() float64 { return 0.1 }
+		_, keep = s.sample(&logrus.Entry{Level: logrus.InfoLevel})
+		assert.True(t, keep)
+	})
+
+	t.Run("key", func(t *testing.T) {
+		s := &Sampling{Rates: map[logrus.Level]float64{logrus.InfoLevel: 0.5}, Key: "trace_id"}
+		kept := 0
+		for i := 0; i < 1000; i++ {
+			entry := &logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{"trace_id": fmt.Sprint(i)}}
+			_, first := s.sample(entry)
+			for j := 0; j < 3; j++ {
+				_, keep := s.sample(entry)
+				assert.Equal(t, first, keep)
+			}
+			if first {
+				kept++
+			}
+		}
+		assert.InDelta(t, 500, kept, 100)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		var pushed []Message
+		sampling := &Sampling{Rates: map[logrus.Level]float64{logrus.InfoLevel: 0.5}, Key: DefaultTraceIDKey}
+		sampling.random = func() float64 { panic("should sample by trace id") }
+		hook := newTestHook(t, newMockService(func(ctx context.Context, message Message) error {
+			pushed = append(pushed, message)
+			return nil
+		}), func(c *Config) { c.Sampling = sampling })
+		logger := newTestLogger(hook)
+
+		kept := 0
+		for i := 0; i < 200; i++ {
+			traceID := fmt.Sprintf("%032x", i+1)
+			ctx := ContextWithTraceparent(context.Background(), "00-"+traceID+"-00f067aa0ba902b7-01")
+			_, keep := sampling.sample(&logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{DefaultTraceIDKey: traceID}})
+
+			before := len(pushed)
+			for j := 0; j < 3; j++ {
+				logger.WithContext(ctx).Info("request")
+			}
+			if keep {
+				kept++
+				assert.Equal(t, before+3, len(pushed))
+			} else {
+				assert.Equal(t, before, len(pushed))
+			}
+		}
+		assert.InDelta(t, 100, kept, 40)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Error(t, (&Sampling{Rates: map[logrus.Level]float64{logrus.InfoLevel: 0}}).validate())
+		assert.Error(t, (&Sampling{Rates: map[logrus.Level]float64{logrus.InfoLevel: 1.5}}).validate())
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		var pushed []Message
+		sampling := &Sampling{Rates: map[logrus.Level]float64{logrus.InfoLevel: 0.25}, RateKey: "rate"}
+		sampling.random = func() float64 { return 0.5 }
+		hook := newTestHook(t, newMockService(func(ctx context.Context, message Message) error {
+			pushed = append(pushed, message)
+			return nil
+		}), func(c *Config) { c.Sampling = sampling })
+		logger := newTestLogger(hook)
+
+		logger.Info("dropped")
+		logger.Warn("kept")
+		sampling.random = func() float64 { return 0.1 }
+		logger.Info("kept")
+
+		if assert.Len(t, pushed, 2) {
+			assert.NotContains(t, pushed[0].Contents, "rate")
+			assert.Equal(t, "0.25", pushed[1].Contents["rate"])
+		}
+		assert.Equal(t, int64(1), hook.Metrics().Sampled)
+	})
+}
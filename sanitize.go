@@ -0,0 +1,195 @@
+package slsh
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+const (
+	DefaultReservedKeyPrefix = "fields."
+	DefaultMaxKeySize        = 128
+)
+
+// 按日志服务字段名规则修正字段名:
+//   - 保留字段 (例如: "__topic__", "__source__", "__time__") 以及与内置字段冲突的
+//     entry.Data 字段 (例如: MessageKey, LevelKey) 添加 Prefix 前缀
+//   - 非法字符替换为 Replacement, 合法字符为字母, 数字以及 "_-./@"
+//   - 不能以 "." 或数字开头, 长度不超过 MaxSize
+type KeySanitizer struct {
+	Prefix      string // 重命名前缀, 可选, 默认为 "fields."
+	Replacement string // 非法字符替换, 可选, 默认为 "_"
+	MaxSize     int    // 字段名最大字节数, 可选, 默认为 128
+}
+
+// 为与内置字段冲突的字段添加前缀
+func (s KeySanitizer) field(key string, builtin map[string]bool) string {
+	if builtin[key] {
+		return s.prefix() + key
+	}
+	return key
+}
+
+// 按字段名排序依次重命名, 修正后的字段名已存在时先添加 Prefix 前缀,
+// 仍然冲突则追加数字后缀, 例如: "fields.a_b_2"
+func (s KeySanitizer) Modify(contents map[string]string) {
+	var keys []string
+	for k := range contents {
+		if s.key(k) != k {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, from := range keys {
+		to := s.key(from)
+		if _, ok := contents[to]; ok {
+			to = s.unique(s.key(s.prefix()+to), contents)
+		}
+		contents[to] = contents[from]
+		delete(contents, from)
+	}
+}
+
+// 为已被占用的 key 追加数字后缀, 直到不再冲突
+func (s KeySanitizer) unique(key string, contents map[string]string) string {
+	if _, ok := contents[key]; !ok {
+		return key
+	}
+	size := validator.CoalesceInt(s.MaxSize, DefaultMaxKeySize)
+	for i := 2; ; i++ {
+		suffix := "_" + strconv.Itoa(i)
+		base := key
+		if n := size - len(suffix); len(base) > n && n > 0 {
+			base = base[:n]
+		}
+		if _, ok := contents[base+suffix]; !ok {
+			return base + suffix
+		}
+	}
+}
+
+func (s KeySanitizer) key(key string) string {
+	if isReservedKey(key) {
+		key = s.prefix() + key
+	}
+
+	replacement := s.Replacement
+	if replacement == "" {
+		replacement = "_"
+	}
+
+	var sb strings.Builder
+	for i, c := range key {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_', c == '@', c == '-', c == '/':
+			sb.WriteRune(c)
+		case '0' <= c && c <= '9', c == '.':
+			if i == 0 {
+				sb.WriteString(replacement)
+			}
+			sb.WriteRune(c)
+		default:
+			sb.WriteString(replacement)
+		}
+	}
+	if sb.Len() == 0 {
+		sb.WriteString(replacement)
+	}
+
+	key = sb.String()
+	if size := validator.CoalesceInt(s.MaxSize, DefaultMaxKeySize); len(key) > size {
+		key = key[:size]
+	}
+	return key
+}
+
+func (s KeySanitizer) prefix() string {
+	return validator.CoalesceStr(s.Prefix, DefaultReservedKeyPrefix)
+}
+
+func isReservedKey(key string) bool {
+	return len(key) > 4 && strings.HasPrefix(key, "__") && strings.HasSuffix(key, "__") ||
+		strings.HasPrefix(key, "__tag__:")
+}
+
+// 修正字段值, 避免日志服务拒绝写入或显示乱码:
+//   - 非法 UTF-8 替换为 U+FFFD, 或转义为 "\xNN"
+//   - 可选转义控制字符, 例如: "\n", "\u0000"
+//   - 可选按字节数截断, 并添加后缀
+type ValueSanitizer struct {
+	EscapeInvalid  bool   // 将非法 UTF-8 转义为 "\xNN", 可选, 默认替换为 U+FFFD
+	EscapeControl  bool   // 转义控制字符, 可选, 默认保留
+	MaxSize        int    // 字段值最大字节数 (不含后缀), 可选, 默认不限制
+	TruncateSuffix string // 截断后缀, 可选, 默认为 "...(truncated)"
+}
+
+func (s ValueSanitizer) modify(contents map[string]string, m *metrics) {
+	for k, v := range contents {
+		contents[k] = s.value(v, m)
+	}
+}
+
+func (s ValueSanitizer) value(v string, m *metrics) string {
+	if !utf8.ValidString(v) {
+		atomic.AddInt64(&m.InvalidUTF8Values, 1)
+		v = s.fixUTF8(v)
+	}
+	if s.EscapeControl && strings.IndexFunc(v, isControl) >= 0 {
+		atomic.AddInt64(&m.EscapedValues, 1)
+		v = escapeControl(v)
+	}
+	if s.MaxSize > 0 && len(v) > s.MaxSize {
+		atomic.AddInt64(&m.TruncatedValues, 1)
+		suffix := s.TruncateSuffix
+		if suffix == "" {
+			suffix = truncatedSuffix
+		}
+		v = truncate(v, s.MaxSize, suffix)
+	}
+	return v
+}
+
+func (s ValueSanitizer) fixUTF8(v string) string {
+	var sb strings.Builder
+	sb.Grow(len(v))
+	for i := 0; i < len(v); {
+		r, size := utf8.DecodeRuneInString(v[i:])
+		switch {
+		case r != utf8.RuneError || size > 1:
+			sb.WriteString(v[i : i+size])
+		case s.EscapeInvalid:
+			_, _ = fmt.Fprintf(&sb, `\x%02x`, v[i])
+		default:
+			sb.WriteRune(utf8.RuneError)
+		}
+		i += size
+	}
+	return sb.String()
+}
+
+func isControl(r rune) bool { return r < 0x20 || 0x7f <= r && r < 0xa0 }
+
+func escapeControl(v string) string {
+	var sb strings.Builder
+	sb.Grow(len(v))
+	for _, r := range v {
+		switch {
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case isControl(r):
+			_, _ = fmt.Fprintf(&sb, `\u%04x`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
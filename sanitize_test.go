@@ -0,0 +1,134 @@
+package slsh
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySanitizer(t *testing.T) {
+	t.Run("key", func(t *testing.T) {
+		s := KeySanitizer{}
+		for raw, expected := range map[string]string{
+			"normal_key":             "normal_key",
+			"a.b-c/d@e":              "a.b-c/d@e",
+			"__topic__":              "fields.__topic__",
+			"__source__":             "fields.__source__",
+			"__time__":               "fields.__time__",
+			"__tag__:pod":            "fields.__tag___pod",
+			"__":                     "__",
+			"with space":             "with_space",
+			".hidden":                "_.hidden",
+			"1st":                    "_1st",
+			"中文":                     "__",
+			"":                       "_",
+			strings.Repeat("k", 200): strings.Repeat("k", DefaultMaxKeySize),
+		} {
+			assert.Equal(t, expected, s.key(raw), raw)
+		}
+
+		s = KeySanitizer{Prefix: "user_", Replacement: "-", MaxSize: 8}
+		assert.Equal(t, "user___t", s.key("__topic__"))
+		assert.Equal(t, "a-b", s.key("a b"))
+	})
+
+	t.Run("modify", func(t *testing.T) {
+		contents := map[string]string{
+			"__topic__": "t",
+			"a b":       "1",
+			"a_b":       "2",
+			"good":      "3",
+		}
+		KeySanitizer{}.Modify(contents)
+		assert.Equal(t, map[string]string{
+			"fields.__topic__": "t",
+			"fields.a_b":       "1",
+			"a_b":              "2",
+			"good":             "3",
+		}, contents)
+
+		for i := 0; i < 10; i++ {
+			contents = map[string]string{
+				"a b":        "1",
+				"a\tb":       "2",
+				"a_b":        "3",
+				"fields.a_b": "4",
+			}
+			KeySanitizer{}.Modify(contents)
+			assert.Equal(t, map[string]string{
+				"fields.a_b_3": "1",
+				"fields.a_b_2": "2",
+				"a_b":          "3",
+				"fields.a_b":   "4",
+			}, contents)
+		}
+
+		contents = map[string]string{"aa b": "1", "aa_b": "2", "p.aa": "3"}
+		KeySanitizer{Prefix: "p.", MaxSize: 4}.Modify(contents)
+		assert.Equal(t, map[string]string{"p._2": "1", "aa_b": "2", "p.aa": "3"}, contents)
+	})
+
+	t.Run("converter", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, map[string]string{"__source__": "extra"}, nil)
+		entry := &logrus.Entry{
+			Data:    logrus.Fields{"message": "user", "level": 1, "__time__": "0", "user id": "u1"},
+			Time:    time.Now(),
+			Level:   logrus.InfoLevel,
+			Message: "content",
+		}
+
+		msg := c.Message(entry)
+		assert.Equal(t, "user", msg.Contents["message"])
+
+		c.KeySanitizer = &KeySanitizer{}
+		msg = c.Message(entry)
+		assert.Equal(t, map[string]string{
+			"message":           "content",
+			"level":             "6",
+			"fields.message":    "user",
+			"fields.level":      "1",
+			"fields.__time__":   "0",
+			"fields.__source__": "extra",
+			"user_id":           "u1",
+		}, msg.Contents)
+	})
+}
+
+func TestValueSanitizer(t *testing.T) {
+	t.Run("value", func(t *testing.T) {
+		m := &metrics{}
+
+		assert.Equal(t, "ok 中文\n", ValueSanitizer{}.value("ok 中文\n", m))
+		assert.Equal(t, "a�b��", ValueSanitizer{}.value("a\xffb\xe4\xb8", m))
+		assert.Equal(t, `a\xffb\xe4\xb8`, ValueSanitizer{EscapeInvalid: true}.value("a\xffb\xe4\xb8", m))
+		assert.Equal(t, `a\nb\tc\u0000\u007f`, ValueSanitizer{EscapeControl: true}.value("a\nb\tc\x00\x7f", m))
+		assert.Equal(t, "中"+truncatedSuffix, ValueSanitizer{MaxSize: 4}.value("中文", m))
+		assert.Equal(t, "ab~", ValueSanitizer{MaxSize: 2, TruncateSuffix: "~"}.value("abc", m))
+
+		assert.Equal(t, Metrics{
+			InvalidUTF8Values: 2,
+			EscapedValues:     1,
+			TruncatedValues:   2,
+		}, m.Snapshot())
+	})
+
+	t.Run("converter", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.ValueSanitizer = &ValueSanitizer{EscapeControl: true, MaxSize: 8}
+		entry := &logrus.Entry{
+			Data:    logrus.Fields{"body": "\x00\xff\xfe", "long": strings.Repeat("v", 10)},
+			Time:    time.Now(),
+			Level:   logrus.InfoLevel,
+			Message: "line1\nline2",
+		}
+
+		msg := c.Message(entry)
+		assert.Equal(t, `line1\nl`+truncatedSuffix, msg.Contents["message"])
+		assert.Equal(t, "vvvvvvvv"+truncatedSuffix, msg.Contents["long"])
+		assert.Equal(t, `\u0000`+truncatedSuffix, msg.Contents["body"])
+		assert.Equal(t, Metrics{InvalidUTF8Values: 1, EscapedValues: 2, TruncatedValues: 3}, c.metrics.Snapshot())
+	})
+}
@@ -12,23 +12,40 @@ import (
 )
 
 type service struct {
-	BufferSize int
-	Interval   time.Duration
-	Flush      func(...Message) error
-	chMessage  chan Message
-	chQuit     chan struct{}
-	onClose    *sync.Once
-	stopped    bool
+	BufferSize    int
+	Interval      time.Duration
+	MinInterval   time.Duration // 自适应等待时间下限, 与 MaxInterval 同时设置时启用
+	MaxInterval   time.Duration // 自适应等待时间上限
+	PriorityDelay time.Duration // 高优先级日志最长等待时间, 0 表示立即发送
+	Flush         func(ctx context.Context, messages ...Message) error
+	chMessage     chan Message
+	chPriority    chan Message
+	chQuit        chan struct{}
+	onClose       *sync.Once
+	stopped       bool
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
 func NewService(bufferSize int, interval time.Duration, flush func(...Message) error) *service {
+	return NewServiceContext(bufferSize, interval, func(ctx context.Context, messages ...Message) error {
+		return flush(messages...)
+	})
+}
+
+// flush 的 ctx 在 Stop 超时后取消, 用于中止进行中的请求
+func NewServiceContext(bufferSize int, interval time.Duration, flush func(context.Context, ...Message) error) *service {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &service{
 		BufferSize: bufferSize,
 		Interval:   interval,
 		Flush:      flush,
 		chMessage:  make(chan Message, bufferSize),
+		chPriority: make(chan Message, bufferSize),
 		chQuit:     make(chan struct{}),
 		onClose:    &sync.Once{},
+		ctx:        ctx,
+		cancel:     cancel,
 	}
 }
 
@@ -38,10 +55,31 @@ func (s *service) Push(ctx context.Context, message Message) error {
 		return nil
 	}
 
+	return enqueue(ctx, s.chMessage, message)
+}
+
+// 通过独立队列发送高优先级日志, 在 PriorityDelay 内与缓冲区中的日志一起发送
+func (s *service) PushPriority(ctx context.Context, message Message) error {
+	if s.stopped {
+		s.trace("Discard message %v", message)
+		return nil
+	}
+
+	return enqueue(ctx, s.chPriority, message)
+}
+
+// 缓冲区有空间时直接写入, 即使 ctx 已结束; 否则等待空间直至 ctx 结束
+func enqueue(ctx context.Context, ch chan<- Message, message Message) error {
+	select {
+	case ch <- message:
+		return nil
+	default:
+	}
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case s.chMessage <- message:
+	case ch <- message:
 		return nil
 	}
 }
@@ -50,23 +88,36 @@ func (s *service) Start() {
 	s.trace("aliyun-log-service start")
 	defer s.trace("aliyun-log-service stopped")
 
-	flushTime := time.Now()
 	buffer := make([]Message, 0, s.BufferSize)
+	linger := s.clamp(s.Interval)
+	timer := time.NewTimer(linger)
+	stopTimer(timer)
+	var deadline time.Time
+
+	// 缓冲区中最早的日志最多等待到 at
+	schedule := func(at time.Time) {
+		if deadline.IsZero() || at.Before(deadline) {
+			deadline = at
+			stopTimer(timer)
+			timer.Reset(time.Until(at))
+		}
+	}
 
 	tryFlush := func(force bool) {
 		if size := len(buffer); size <= 0 ||
-			!force && size < s.BufferSize && time.Since(flushTime) < s.Interval {
+			!force && size < s.BufferSize && time.Now().Before(deadline) {
 			return
 		}
 
+		st := time.Now()
 		defer func() {
-			flushTime = time.Now()
+			linger = s.adapt(linger, len(buffer), time.Since(st))
+			deadline = time.Time{}
+			stopTimer(timer)
 			buffer = buffer[:0]
 		}()
 
-		st := time.Now()
-
-		if err := s.Flush(buffer...); err != nil {
+		if err := s.Flush(s.ctx, buffer...); err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "Fail to flush logs: %v\n", err)
 			return
 		}
@@ -77,30 +128,87 @@ func (s *service) Start() {
 
 Loop:
 	for {
-		timer := time.NewTimer(s.Interval / 10)
 		select {
 		case <-timer.C:
+		case message, ok := <-s.chPriority:
+			if !ok {
+				break Loop
+			}
+			buffer = append(buffer, message)
+			schedule(time.Now().Add(s.PriorityDelay))
 		case message, ok := <-s.chMessage:
 			if !ok {
 				break Loop
 			}
 			buffer = append(buffer, message)
+			schedule(time.Now().Add(linger))
 		}
 		tryFlush(false)
-		timer.Stop()
 	}
 
+	for message := range s.chPriority {
+		buffer = append(buffer, message)
+		tryFlush(false)
+	}
+	for message := range s.chMessage {
+		buffer = append(buffer, message)
+		tryFlush(false)
+	}
 	tryFlush(true)
+	stopTimer(timer)
+	s.cancel()
 	close(s.chQuit)
 }
 
+func (s *service) adaptive() bool { return s.MinInterval > 0 && s.MaxInterval > 0 }
+
+func (s *service) clamp(linger time.Duration) time.Duration {
+	if !s.adaptive() {
+		return linger
+	}
+	if linger < s.MinInterval {
+		return s.MinInterval
+	}
+	if linger > s.MaxInterval {
+		return s.MaxInterval
+	}
+	return linger
+}
+
+// 根据本次发送的日志数与耗时调整等待时间:
+//   - 缓冲区写满或发送耗时超过等待时间的一半, 说明负载较高, 加倍等待时间以增大批次
+//   - 日志数不足缓冲区的 1/4, 说明流量较低, 减半等待时间以尽快发送
+func (s *service) adapt(linger time.Duration, size int, latency time.Duration) time.Duration {
+	if !s.adaptive() {
+		return linger
+	}
+	switch {
+	case size >= s.BufferSize || latency > linger/2:
+		linger *= 2
+	case size < s.BufferSize/4:
+		linger /= 2
+	}
+	return s.clamp(linger)
+}
+
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
 func (s *service) Stop(ctx context.Context) (err error) {
 	s.onClose.Do(func() {
 		s.stopped = true
+		close(s.chPriority)
 		close(s.chMessage)
 		select {
 		case <-ctx.Done():
 			err = ctx.Err()
+			s.cancel()
 		case <-s.chQuit:
 		}
 	})
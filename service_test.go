@@ -63,7 +63,8 @@ func TestService(t *testing.T) {
 
 		go s.Start()
 
-		ctx, _ := context.WithTimeout(context.TODO(), 2*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.TODO(), 2*time.Millisecond)
+		defer cancel()
 		err := s.Push(ctx, Message{})
 		assert.NoError(t, err)
 
@@ -74,6 +75,19 @@ func TestService(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("canceled context", func(t *testing.T) {
+		s := NewService(1000, time.Hour, func(messages ...Message) error { return nil })
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		for i := 0; i < 1000; i++ {
+			assert.NoError(t, s.Push(ctx, Message{}))
+			assert.NoError(t, s.PushPriority(ctx, Message{}))
+		}
+		assert.Equal(t, context.Canceled, s.Push(ctx, Message{}))
+		assert.Equal(t, context.Canceled, s.PushPriority(ctx, Message{}))
+	})
+
 	t.Run("close timeout", func(t *testing.T) {
 		s := NewService(0, time.Millisecond,
 			func(messages ...Message) error { time.Sleep(100 * time.Millisecond); return nil })
@@ -83,7 +97,8 @@ func TestService(t *testing.T) {
 		err := s.Push(context.TODO(), Message{})
 		assert.NoError(t, err)
 
-		ctx, _ := context.WithTimeout(context.TODO(), 2*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.TODO(), 2*time.Millisecond)
+		defer cancel()
 		err = s.Stop(ctx)
 		assert.Error(t, err, context.DeadlineExceeded)
 	})
@@ -100,4 +115,104 @@ func TestService(t *testing.T) {
 		err = s.Stop(context.TODO())
 		assert.NoError(t, err)
 	})
+	t.Run("priority", func(t *testing.T) {
+		flushed := make(chan []Message, 1)
+		s := NewService(10, time.Hour,
+			func(messages ...Message) error { flushed <- append([]Message(nil), messages...); return nil })
+		s.PriorityDelay = 10 * time.Millisecond
+
+		go s.Start()
+
+		assert.NoError(t, s.Push(context.TODO(), Message{Contents: map[string]string{"level": "info"}}))
+		assert.NoError(t, s.PushPriority(context.TODO(), Message{Contents: map[string]string{"level": "error"}}))
+
+		select {
+		case messages := <-flushed:
+			assert.Len(t, messages, 2)
+		case <-time.After(time.Second):
+			assert.Fail(t, "priority message not flushed")
+		}
+
+		assert.NoError(t, s.PushPriority(context.TODO(), Message{}))
+		assert.NoError(t, s.Stop(context.TODO()))
+		assert.Len(t, <-flushed, 1)
+	})
+	t.Run("cancel in-flight", func(t *testing.T) {
+		canceled := make(chan error, 1)
+		s := NewServiceContext(0, time.Millisecond, func(ctx context.Context, messages ...Message) error {
+			<-ctx.Done()
+			canceled <- ctx.Err()
+			return ctx.Err()
+		})
+
+		go s.Start()
+
+		assert.NoError(t, s.Push(context.TODO(), Message{}))
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, s.Stop(ctx))
+
+		select {
+		case err := <-canceled:
+			assert.Equal(t, context.Canceled, err)
+		case <-time.After(time.Second):
+			assert.Fail(t, "in-flight flush not canceled")
+		}
+	})
+
+	t.Run("adaptive", func(t *testing.T) {
+		s := NewService(100, time.Second, nil)
+		assert.Equal(t, time.Second, s.adapt(time.Second, 1, 0))
+
+		s.MinInterval, s.MaxInterval = 100*time.Millisecond, 4*time.Second
+		assert.Equal(t, 500*time.Millisecond, s.adapt(time.Second, 1, 0))
+		assert.Equal(t, 100*time.Millisecond, s.adapt(150*time.Millisecond, 1, 0))
+		assert.Equal(t, time.Second, s.adapt(time.Second, 50, 0))
+		assert.Equal(t, 2*time.Second, s.adapt(time.Second, 100, 0))
+		assert.Equal(t, 2*time.Second, s.adapt(time.Second, 50, 600*time.Millisecond))
+		assert.Equal(t, 4*time.Second, s.adapt(3*time.Second, 100, 0))
+		assert.Equal(t, 4*time.Second, s.clamp(time.Minute))
+	})
+
+	t.Run("adaptive flush", func(t *testing.T) {
+		flushed := make(chan int, 10)
+		s := NewService(100, time.Second,
+			func(messages ...Message) error { flushed <- len(messages); return nil })
+		s.MinInterval, s.MaxInterval = 10*time.Millisecond, time.Second
+
+		go s.Start()
+
+		assert.NoError(t, s.Push(context.TODO(), Message{}))
+		assert.Equal(t, 1, <-flushed)
+
+		st := time.Now()
+		assert.NoError(t, s.Push(context.TODO(), Message{}))
+		assert.Equal(t, 1, <-flushed)
+		assert.True(t, time.Since(st) < time.Second)
+
+		assert.NoError(t, s.Stop(context.TODO()))
+	})
+}
+
+func BenchmarkService(b *testing.B) {
+	bench := func(b *testing.B, minInterval, maxInterval time.Duration) {
+		s := NewService(DefaultBufferSize, 10*time.Millisecond, func(messages ...Message) error { return nil })
+		s.MinInterval, s.MaxInterval = minInterval, maxInterval
+
+		go s.Start()
+
+		ctx := context.Background()
+		message := Message{Contents: map[string]string{"message": "content"}}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = s.Push(ctx, message)
+		}
+		_ = s.Stop(ctx)
+	}
+
+	b.Run("fixed", func(b *testing.B) { bench(b, 0, 0) })
+	b.Run("adaptive", func(b *testing.B) { bench(b, time.Millisecond, 100*time.Millisecond) })
 }
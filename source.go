@@ -0,0 +1,94 @@
+package slsh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// 日志 __source__ 字段来源
+type SourceProvider func() (string, error)
+
+// 网卡及其地址
+type NetInterface struct {
+	Name  string
+	Flags net.Flags
+	Addrs []net.Addr
+}
+
+// 网卡列表, 测试时可替换
+type InterfaceLister interface {
+	Interfaces() ([]NetInterface, error)
+}
+
+type InterfaceListerFunc func() ([]NetInterface, error)
+
+func (f InterfaceListerFunc) Interfaces() ([]NetInterface, error) { return f() }
+
+// 读取本机网卡
+var SystemInterfaces InterfaceLister = InterfaceListerFunc(func() ([]NetInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]NetInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, NetInterface{Name: iface.Name, Flags: iface.Flags, Addrs: addrs})
+	}
+	return result, nil
+})
+
+// 主机名, 即默认来源
+func HostnameSource() SourceProvider {
+	return os.Hostname
+}
+
+// 读取环境变量 name
+func EnvSource(name string) SourceProvider {
+	return func() (string, error) {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("env %s is empty", name)
+	}
+}
+
+// 本机网卡 iface (为空时不限) 的第一个非 loopback IPv4 地址, ipv6 为 true 时取 IPv6 地址
+func IPSource(iface string, ipv6 bool) SourceProvider {
+	return IPSourceFrom(SystemInterfaces, iface, ipv6)
+}
+
+func IPSourceFrom(lister InterfaceLister, iface string, ipv6 bool) SourceProvider {
+	return func() (string, error) {
+		ifaces, err := lister.Interfaces()
+		if err != nil {
+			return "", err
+		}
+		for _, i := range ifaces {
+			if iface != "" && i.Name != iface ||
+				i.Flags&net.FlagUp == 0 || i.Flags&net.FlagLoopback != 0 {
+				continue
+			}
+			for _, addr := range i.Addrs {
+				var ip net.IP
+				switch addr := addr.(type) {
+				case *net.IPNet:
+					ip = addr.IP
+				case *net.IPAddr:
+					ip = addr.IP
+				}
+				if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || (ip.To4() == nil) != ipv6 {
+					continue
+				}
+				return ip.String(), nil
+			}
+		}
+		return "", errors.New("no matched ip address")
+	}
+}
@@ -0,0 +1,88 @@
+package slsh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	cidr := func(s string) net.Addr {
+		ip, ipNet, _ := net.ParseCIDR(s)
+		ipNet.IP = ip
+		return ipNet
+	}
+	lister := InterfaceListerFunc(func() ([]NetInterface, error) {
+		return []NetInterface{
+			{Name: "lo", Flags: net.FlagUp | net.FlagLoopback, Addrs: []net.Addr{cidr("127.0.0.1/8"), cidr("::1/128")}},
+			{Name: "eth0", Flags: 0, Addrs: []net.Addr{cidr("10.0.0.9/24")}},
+			{Name: "eth1", Flags: net.FlagUp, Addrs: []net.Addr{cidr("fe80::1/64"), cidr("2001:db8::1/64"), cidr("10.0.1.2/24")}},
+			{Name: "eth2", Flags: net.FlagUp, Addrs: []net.Addr{&net.IPAddr{IP: net.ParseIP("192.168.0.2")}}},
+		}, nil
+	})
+
+	t.Run("ip", func(t *testing.T) {
+		for _, c := range []struct {
+			iface    string
+			ipv6     bool
+			expected string
+		}{
+			{"", false, "10.0.1.2"},
+			{"", true, "2001:db8::1"},
+			{"eth2", false, "192.168.0.2"},
+		} {
+			source, err := IPSourceFrom(lister, c.iface, c.ipv6)()
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, source)
+		}
+
+		for _, iface := range []string{"lo", "eth0", "missing"} {
+			_, err := IPSourceFrom(lister, iface, false)()
+			assert.Error(t, err, iface)
+		}
+
+		_, err := IPSourceFrom(InterfaceListerFunc(func() ([]NetInterface, error) {
+			return nil, errors.New("any")
+		}), "", false)()
+		assert.Error(t, err)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		const name = "SLSH_TEST_SOURCE"
+		_ = os.Setenv(name, "10.0.0.3")
+		defer func() { _ = os.Unsetenv(name) }()
+
+		source, err := EnvSource(name)()
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.3", source)
+
+		_, err = EnvSource(name + "_MISSING")()
+		assert.Error(t, err)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		c := testConfig(nil)
+		c.Source = ""
+		c.SourceProvider = IPSourceFrom(lister, "eth2", false)
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, "192.168.0.2", c.Source)
+		}
+
+		c = testConfig(nil)
+		c.Source = ""
+		c.SourceProvider = func() (string, error) { return "", errors.New("any") }
+		if assert.NoError(t, c.validate()) {
+			hostname, _ := os.Hostname()
+			assert.Equal(t, hostname, c.Source)
+		}
+
+		c = testConfig(nil)
+		c.SourceProvider = func() (string, error) { return "custom", nil }
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, "10.0.0.1", c.Source)
+		}
+	})
+}
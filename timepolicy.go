@@ -0,0 +1,64 @@
+package slsh
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMaxAge    = 7 * 24 * time.Hour
+	DefaultMaxFuture = 15 * time.Minute
+	OriginalTimeKey  = "__original_time__"
+)
+
+// 日志时间超出 [now - MaxAge, now + MaxFuture] 时的处理方式, 日志服务会拒绝超出范围的日志
+type TimePolicy int
+
+const (
+	TimePassThrough TimePolicy = iota // 原样发送
+	TimeClamp                         // 使用当前时间, 原始时间写入 "__original_time__"
+	TimeDrop                          // 丢弃日志并计数
+)
+
+// 处理零值与超出范围的日志时间, 零值总是替换为当前时间
+func (w *writer) checkTime(messages []Message) []Message {
+	now := w.now()
+	min, max := now.Add(-w.maxAge()), now.Add(w.maxFuture())
+
+	kept := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		switch {
+		case message.Time.IsZero():
+			message.Time = now
+		case w.TimePolicy == TimePassThrough,
+			!message.Time.Before(min) && !message.Time.After(max):
+		case w.TimePolicy == TimeDrop:
+			atomic.AddInt64(&w.metrics.DroppedTimes, 1)
+			continue
+		default:
+			atomic.AddInt64(&w.metrics.ClampedTimes, 1)
+			contents := make(map[string]string, len(message.Contents)+1)
+			for k, v := range message.Contents {
+				contents[k] = v
+			}
+			contents[OriginalTimeKey] = message.Time.Format(time.RFC3339Nano)
+			message.Contents, message.Time = contents, now
+		}
+		kept = append(kept, message)
+	}
+	return kept
+}
+
+func (w *writer) maxAge() time.Duration {
+	if w.MaxAge > 0 {
+		return w.MaxAge
+	}
+	return DefaultMaxAge
+}
+
+func (w *writer) maxFuture() time.Duration {
+	if w.MaxFuture > 0 {
+		return w.MaxFuture
+	}
+	return DefaultMaxFuture
+}
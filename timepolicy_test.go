@@ -0,0 +1,74 @@
+package slsh
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/api"
+)
+
+func TestTimePolicy(t *testing.T) {
+	now := time.Now()
+	old := Message{Time: now.Add(-30 * 24 * time.Hour), Contents: map[string]string{"k": "old"}}
+	future := Message{Time: now.Add(time.Hour), Contents: map[string]string{"k": "future"}}
+	valid := Message{Time: now.Add(-time.Hour), Contents: map[string]string{"k": "valid"}}
+	zero := Message{Contents: map[string]string{"k": "zero"}}
+	messages := []Message{old, future, valid, zero}
+
+	newWriter := func(policy TimePolicy) *writer {
+		w := NewWriter(&url.URL{}, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, nil)
+		w.TimePolicy = policy
+		return w
+	}
+
+	t.Run("pass through", func(t *testing.T) {
+		kept := newWriter(TimePassThrough).checkTime(messages)
+		if assert.Len(t, kept, 4) {
+			assert.Equal(t, old.Time, kept[0].Time)
+			assert.Equal(t, future.Time, kept[1].Time)
+			assert.WithinDuration(t, now, kept[3].Time, time.Second)
+		}
+	})
+
+	t.Run("clamp", func(t *testing.T) {
+		w := newWriter(TimeClamp)
+		kept := w.checkTime(messages)
+		if assert.Len(t, kept, 4) {
+			assert.WithinDuration(t, now, kept[0].Time, time.Second)
+			assert.Equal(t, old.Time.Format(time.RFC3339Nano), kept[0].Contents[OriginalTimeKey])
+			assert.WithinDuration(t, now, kept[1].Time, time.Second)
+			assert.Equal(t, valid, kept[2])
+			assert.NotContains(t, kept[3].Contents, OriginalTimeKey)
+		}
+		assert.NotContains(t, old.Contents, OriginalTimeKey)
+		assert.Equal(t, int64(2), w.metrics.Snapshot().ClampedTimes)
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		w := newWriter(TimeDrop)
+		w.MaxAge = 10 * time.Minute
+		kept := w.checkTime(messages)
+		if assert.Len(t, kept, 1) {
+			assert.Equal(t, "zero", kept[0].Contents["k"])
+		}
+		assert.Equal(t, int64(3), w.metrics.Snapshot().DroppedTimes)
+
+		assert.NoError(t, w.WriteMessage(old, future))
+	})
+
+	t.Run("encode zero", func(t *testing.T) {
+		w := newWriter(TimePassThrough)
+		raw, err := w.encode(w.checkTime([]Message{zero})...)
+		if !assert.NoError(t, err) {
+			return
+		}
+		group := &api.LogGroup{}
+		if assert.NoError(t, proto.Unmarshal(raw, group)) {
+			assert.InDelta(t, now.Unix(), int64(group.Logs[0].GetTime()), 1)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package slsh
+
+import (
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/internal/validator"
+)
+
+const modulePath = "github.com/GotaX/logrus-aliyun-log-hook"
+
+// 当前版本, 取自依赖方的构建信息, 无法获取时为 "devel"
+var Version = moduleVersion()
+
+var userAgent = []string{"logrus-aliyun-log-hook/" + Version}
+
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "devel"
+}
+
+// 日志服务专用 Transport, 不与 http.DefaultTransport 共享连接池,
+// responseHeaderTimeout 为等待响应头的超时, 0 表示不限制
+func NewTransport(responseHeaderTimeout time.Duration) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          32,
+		MaxIdleConnsPerHost:   8,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// Config.HttpClient 的默认值, 以 requestTimeout (默认为 10s) 作为响应头超时,
+// 不设置 http.Client.Timeout, 以免与 Config.RequestTimeout 冲突
+func NewHTTPClient(requestTimeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: NewTransport(validator.CoalesceDur(requestTimeout, DefaultRequestTimeout)),
+	}
+}
@@ -0,0 +1,47 @@
+package slsh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPClient(t *testing.T) {
+	client := NewHTTPClient(0)
+	assert.Zero(t, client.Timeout)
+
+	transport, ok := client.Transport.(*http.Transport)
+	if assert.True(t, ok) {
+		assert.True(t, transport.ForceAttemptHTTP2)
+		assert.NotZero(t, transport.MaxIdleConnsPerHost)
+		assert.NotZero(t, transport.TLSHandshakeTimeout)
+		assert.Equal(t, DefaultRequestTimeout, transport.ResponseHeaderTimeout)
+		assert.False(t, transport == http.DefaultTransport)
+	}
+
+	t.Run("request timeout", func(t *testing.T) {
+		transport := NewHTTPClient(3 * time.Second).Transport.(*http.Transport)
+		assert.Equal(t, 3*time.Second, transport.ResponseHeaderTimeout)
+	})
+
+	t.Run("user agent", func(t *testing.T) {
+		var agent string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			agent = req.UserAgent()
+		}))
+		defer srv.Close()
+
+		u, err := url.Parse(srv.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, client)
+		if assert.NoError(t, w.WriteMessage(ShortMessage)) {
+			assert.Equal(t, "logrus-aliyun-log-hook/"+Version, agent)
+		}
+	})
+}
@@ -13,10 +13,11 @@ type Secret []byte
 func (s Secret) String() string { return "******" }
 
 type AliyunError struct {
-	HTTPCode  int32  `json:"-"`
-	Code      string `json:"errorCode"`
-	Message   string `json:"errorMessage"`
-	RequestID string `json:"-"`
+	HTTPCode   int32     `json:"-"`
+	Code       string    `json:"errorCode"`
+	Message    string    `json:"errorMessage"`
+	RequestID  string    `json:"-"`
+	ServerTime time.Time `json:"-"` // 服务端时间, 仅 RequestTimeTooSkewed 时设置
 }
 
 func (a AliyunError) Error() string {
@@ -30,12 +31,35 @@ func (a AliyunError) Error() string {
 type Message struct {
 	Time     time.Time
 	Contents map[string]string
+	Store    string // 目标 logstore, 可选, 默认为 Config.Store
 }
 
 type Writer interface {
 	WriteMessage(messages ...Message) error
 }
 
+// 支持取消的 Writer, ctx 取消时应中止进行中的请求
+type ContextWriter interface {
+	WriteMessageContext(ctx context.Context, messages ...Message) error
+}
+
+type writerAdapter struct{ Writer }
+
+func (w writerAdapter) WriteMessageContext(ctx context.Context, messages ...Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.WriteMessage(messages...)
+}
+
+// 将 Writer 转换为 ContextWriter, 未实现 ContextWriter 的 Writer 仅在写入前检查 ctx
+func AdaptWriter(w Writer) ContextWriter {
+	if cw, ok := w.(ContextWriter); ok {
+		return cw
+	}
+	return writerAdapter{w}
+}
+
 type Service interface {
 	Push(ctx context.Context, message Message) error
 	Start()
@@ -2,17 +2,20 @@ package slsh
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -30,7 +33,7 @@ var (
 
 var loc = time.FixedZone("GMT", 0)
 
-func gmtNow() string { return time.Now().In(loc).Format(time.RFC1123) }
+const codeTimeTooSkewed = "RequestTimeTooSkewed"
 
 type writer struct {
 	client    *http.Client
@@ -41,6 +44,14 @@ type writer struct {
 	hHost     []string
 	topic     string
 	source    string
+	Tags      map[string]string
+	Timeout   time.Duration // 单次请求超时, 0 表示不限制
+
+	TimePolicy TimePolicy    // 日志时间超出范围时的处理方式, 默认原样发送
+	MaxAge     time.Duration // 日志时间最早为 now - MaxAge, 默认为 7 天
+	MaxFuture  time.Duration // 日志时间最晚为 now + MaxFuture, 默认为 15m
+
+	metrics *metrics
 }
 
 func NewWriter(uri *url.URL, topic, source, accessKey string, accessSecret Secret, client *http.Client) *writer {
@@ -53,14 +64,51 @@ func NewWriter(uri *url.URL, topic, source, accessKey string, accessSecret Secre
 		source:    source,
 		appKey:    accessKey,
 		appSecret: accessSecret,
+		metrics:   &metrics{},
 	}
 }
 
 func (w *writer) WriteMessage(messages ...Message) error {
-	if len(messages) == 0 {
+	return w.WriteMessageContext(context.Background(), messages...)
+}
+
+func (w *writer) WriteMessageContext(ctx context.Context, messages ...Message) error {
+	if messages = w.checkTime(messages); len(messages) == 0 {
 		return nil
 	}
 
+	stores, groups := groupByStore(messages)
+	for _, store := range stores {
+		if err := w.write(ctx, w.storeURI(store), groups[store]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按 Message.Store 分组, 保持首次出现的顺序
+func groupByStore(messages []Message) ([]string, map[string][]Message) {
+	var stores []string
+	groups := make(map[string][]Message, 1)
+	for _, message := range messages {
+		if _, ok := groups[message.Store]; !ok {
+			stores = append(stores, message.Store)
+		}
+		groups[message.Store] = append(groups[message.Store], message)
+	}
+	return stores, groups
+}
+
+func (w *writer) storeURI(store string) *url.URL {
+	if store == "" {
+		return w.uri
+	}
+	uri := *w.uri
+	uri.Path = fmt.Sprintf("/logstores/%s/shards/lb", store)
+	return &uri
+}
+
+func (w *writer) write(ctx context.Context, uri *url.URL, messages ...Message) error {
 	raw, err := w.encode(messages...)
 	if err != nil {
 		return err
@@ -71,14 +119,43 @@ func (w *writer) WriteMessage(messages ...Message) error {
 		return err
 	}
 
-	req, err := w.buildRequest(raw, data)
-	if err != nil {
-		return err
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
 	}
 
-	return w.fire(req)
+	for retried := false; ; retried = true {
+		req, err := w.buildRequest(ctx, uri, raw, data)
+		if err != nil {
+			return err
+		}
+
+		err = w.fire(req)
+		if retried || !w.syncClock(err) {
+			return err
+		}
+	}
 }
 
+// 服务端返回 RequestTimeTooSkewed 时按响应的 Date 校正本地时钟, 返回 true 表示需要重新签名并重试
+func (w *writer) syncClock(err error) bool {
+	var aErr *AliyunError
+	if !errors.As(err, &aErr) || aErr.Code != codeTimeTooSkewed || aErr.ServerTime.IsZero() {
+		return false
+	}
+	offset := time.Until(aErr.ServerTime)
+	atomic.StoreInt64((*int64)(&w.metrics.ClockOffset), int64(offset))
+	return true
+}
+
+// 按 ClockOffset 校正后的当前时间
+func (w *writer) now() time.Time {
+	return time.Now().Add(time.Duration(atomic.LoadInt64((*int64)(&w.metrics.ClockOffset))))
+}
+
+func (w *writer) gmtNow() string { return w.now().In(loc).Format(time.RFC1123) }
+
 func (w *writer) encode(messages ...Message) ([]byte, error) {
 	group := &api.LogGroup{
 		Topic:  &w.topic,
@@ -86,6 +163,13 @@ func (w *writer) encode(messages ...Message) ([]byte, error) {
 		Logs:   make([]*api.Log, len(messages)),
 	}
 
+	for k, v := range w.Tags {
+		group.LogTags = append(group.LogTags, &api.LogTag{
+			Key:   proto.String(k),
+			Value: proto.String(v),
+		})
+	}
+
 	for i, message := range messages {
 		contents := make([]*api.Log_Content, 0, len(message.Contents))
 		for k, v := range message.Contents {
@@ -117,8 +201,8 @@ func (w *writer) compress(data []byte) ([]byte, error) {
 	return out[:n], nil
 }
 
-func (w *writer) buildRequest(raw, data []byte) (*http.Request, error) {
-	req, err := http.NewRequest(w.method, w.uri.String(), bytes.NewReader(data))
+func (w *writer) buildRequest(ctx context.Context, uri *url.URL, raw, data []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, w.method, uri.String(), bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -127,8 +211,9 @@ func (w *writer) buildRequest(raw, data []byte) (*http.Request, error) {
 		"Content-Type":          hContentType,
 		"Content-Length":        []string{strconv.Itoa(len(data))},
 		"Content-Md5":           []string{fmt.Sprintf("%X", md5.Sum(data))},
-		"Date":                  []string{gmtNow()},
+		"Date":                  []string{w.gmtNow()},
 		"Host":                  w.hHost,
+		"User-Agent":            userAgent,
 		"X-Log-Apiversion":      hApiVersion,
 		"X-Log-Bodyrawsize":     []string{strconv.Itoa(len(raw))},
 		"X-Log-Compresstype":    hCompressType,
@@ -165,6 +250,9 @@ func (w writer) validateResponse(resp *http.Response) error {
 	if err := json.NewDecoder(resp.Body).Decode(&aErr); err != nil {
 		return err
 	}
+	if aErr.Code == codeTimeTooSkewed {
+		aErr.ServerTime, _ = http.ParseTime(resp.Header.Get("Date"))
+	}
 	return &aErr
 }
 
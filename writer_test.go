@@ -1,6 +1,7 @@
 package slsh
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -14,6 +15,8 @@ import (
 	sls "github.com/aliyun/aliyun-log-go-sdk"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/GotaX/logrus-aliyun-log-hook/api"
 )
 
 // {"errorCode":"ParameterInvalid","errorMessage":"http extend authorization : LOG :WL2xp3EYvKpsIGgwE3s5HHK7M/c= pair is invalid"}
@@ -116,6 +119,136 @@ func TestWriter(t *testing.T) {
 	})
 }
 
+func TestWriterTags(t *testing.T) {
+	w := NewWriter(&url.URL{}, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, nil)
+	w.Tags = map[string]string{"k8s.pod.name": "demo"}
+
+	raw, err := w.encode(ShortMessage)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	group := &api.LogGroup{}
+	if assert.NoError(t, proto.Unmarshal(raw, group)) && assert.Len(t, group.LogTags, 1) {
+		assert.Equal(t, "k8s.pod.name", group.LogTags[0].GetKey())
+		assert.Equal(t, "demo", group.LogTags[0].GetValue())
+	}
+}
+
+func TestWriterStore(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/logstores/test-store/shards/lb")
+	if !assert.NoError(t, err) {
+		return
+	}
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+
+	audit := ShortMessage
+	audit.Store = "audit"
+	assert.NoError(t, w.WriteMessage(ShortMessage, audit, LongMessage, audit))
+	assert.Equal(t, []string{"/logstores/test-store/shards/lb", "/logstores/audit/shards/lb"}, paths)
+}
+
+func TestWriterContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	u, err := url.Parse(srv.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := w.WriteMessageContext(ctx, ShortMessage)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		w.Timeout = 10 * time.Millisecond
+		err := w.WriteMessage(ShortMessage)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("adapter", func(t *testing.T) {
+		assert.Equal(t, w, AdaptWriter(w))
+
+		written := 0
+		cw := AdaptWriter(&MockWriter{onWriteMessage: func(messages ...Message) error { written += len(messages); return nil }})
+		assert.NoError(t, cw.WriteMessageContext(context.Background(), Messages...))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, cw.WriteMessageContext(ctx, ShortMessage))
+		assert.Equal(t, 2, written)
+	})
+}
+
+func TestWriterClockSkew(t *testing.T) {
+	serverTime := time.Now().Add(time.Hour)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		date, err := http.ParseTime(req.Header.Get("Date"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		if d := serverTime.Sub(date); d > 15*time.Minute || d < -15*time.Minute {
+			w.Header().Set("Date", serverTime.UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(AliyunError{Code: codeTimeTooSkewed, Message: "too skewed"})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+
+	assert.NoError(t, w.WriteMessage(ShortMessage))
+	assert.Equal(t, 2, requests)
+	assert.InDelta(t, float64(time.Hour), float64(w.metrics.Snapshot().ClockOffset), float64(2*time.Second))
+
+	assert.NoError(t, w.WriteMessage(ShortMessage))
+	assert.Equal(t, 3, requests)
+
+	t.Run("retry once", func(t *testing.T) {
+		serverTime = serverTime.Add(time.Hour)
+		w.metrics.ClockOffset = 0
+		srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			w.Header().Set("Date", serverTime.UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(AliyunError{Code: codeTimeTooSkewed})
+		})
+
+		var aErr *AliyunError
+		if assert.True(t, errors.As(w.WriteMessage(ShortMessage), &aErr)) {
+			assert.Equal(t, codeTimeTooSkewed, aErr.Code)
+		}
+		assert.Equal(t, 5, requests)
+	})
+}
+
 func TestSignature(t *testing.T) {
 	uri := "http://test-project.regionid.example.com/logstores/test-logstore"
 	req, err := http.NewRequest("POST", uri, nil)